@@ -0,0 +1,100 @@
+package metadata
+
+import (
+	"strings"
+	"sync"
+)
+
+// schemaCache holds the fields and indices read from the catalog per table.
+// A nil cache caches nothing.
+type schemaCache struct {
+	mu      sync.RWMutex
+	fields  map[string][]Field
+	indices map[string][]Indices
+}
+
+func newSchemaCache() *schemaCache {
+	return &schemaCache{
+		fields:  make(map[string][]Field),
+		indices: make(map[string][]Indices),
+	}
+}
+
+func cacheKey(database, table string) string {
+	return database + "." + table
+}
+
+func (c *schemaCache) getFields(database, table string) ([]Field, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	fields, ok := c.fields[cacheKey(database, table)]
+	// Callers may modify the fields they get, so they get a copy.
+	return append([]Field(nil), fields...), ok
+}
+
+func (c *schemaCache) setFields(database, table string, fields []Field) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.fields[cacheKey(database, table)] = append([]Field(nil), fields...)
+}
+
+func (c *schemaCache) getIndices(database, table string) ([]Indices, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	indices, ok := c.indices[cacheKey(database, table)]
+	return append([]Indices(nil), indices...), ok
+}
+
+func (c *schemaCache) setIndices(database, table string, indices []Indices) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.indices[cacheKey(database, table)] = append([]Indices(nil), indices...)
+}
+
+// invalidate drops table in every database, or everything when table is empty.
+func (c *schemaCache) invalidate(table string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.fields {
+		if table == "" || strings.HasSuffix(key, "."+table) {
+			delete(c.fields, key)
+		}
+	}
+	for key := range c.indices {
+		if table == "" || strings.HasSuffix(key, "."+table) {
+			delete(c.indices, key)
+		}
+	}
+}
+
+var ddlKeywords = []string{"ALTER", "CREATE", "DROP", "RENAME", "COMMENT"}
+
+// invalidateOnDDL clears the whole cache when sql may change a table. The
+// tables a script touches aren't parsed out, so any DDL clears everything.
+func (c *schemaCache) invalidateOnDDL(sql string) {
+	if c == nil {
+		return
+	}
+	for _, statement := range splitStatements(sql) {
+		words := strings.Fields(statement)
+		if len(words) > 0 && contains(ddlKeywords, strings.ToUpper(words[0])) {
+			c.invalidate("")
+			return
+		}
+	}
+}
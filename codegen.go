@@ -0,0 +1,223 @@
+package metadata
+
+import (
+	"fmt"
+	"go/format"
+	"sort"
+	"strings"
+)
+
+var goInitialisms = map[string]string{
+	"id":   "ID",
+	"url":  "URL",
+	"uri":  "URI",
+	"uuid": "UUID",
+	"api":  "API",
+	"json": "JSON",
+	"sql":  "SQL",
+	"ip":   "IP",
+	"http": "HTTP",
+}
+
+// goName converts a table or column name to an exported Go identifier.
+func goName(name string) string {
+	parts := strings.FieldsFunc(name, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9')
+	})
+	var sb strings.Builder
+	for _, part := range parts {
+		if v, ok := goInitialisms[strings.ToLower(part)]; ok {
+			sb.WriteString(v)
+			continue
+		}
+		sb.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	ident := sb.String()
+	if ident == "" || ident[0] >= '0' && ident[0] <= '9' {
+		ident = "Field" + ident
+	}
+	return ident
+}
+
+// goType maps the data type of a field to a Go type. Nullable fields are
+// mapped to pointers.
+func goType(f Field) string {
+	var typ string
+	switch strings.ToLower(f.DataType) {
+	case "bool", "boolean":
+		typ = "bool"
+	case "tinyint":
+		if f.Length == 1 {
+			typ = "bool"
+		} else {
+			typ = "int8"
+		}
+	case "smallint", "int2", "year":
+		typ = "int16"
+	case "mediumint":
+		typ = "int32"
+	case "int", "integer", "int4", "serial", "serial4", "bigint", "int8", "bigserial", "serial8", "big_integer", "biginteger":
+		typ = "int64"
+	case "float", "real", "float4":
+		typ = "float32"
+	case "double", "double precision", "float8", "numeric", "decimal":
+		typ = "float64"
+	case "date", "datetime", "timestamp", "timestamptz", "timestamp with time zone", "timestamp without time zone":
+		typ = "time.Time"
+	case "json", "jsonb":
+		return "json.RawMessage"
+	case "bytea", "blob", "longblob", "binary", "varbinary":
+		return "[]byte"
+	default:
+		typ = "string"
+	}
+	if strings.ToUpper(f.IsNullable) == "YES" {
+		return "*" + typ
+	}
+	return typ
+}
+
+// ToGoStruct generates a Go struct for table with gorm and json tags derived
+// from the fields and, when given, the constraints of the table.
+func ToGoStruct(table string, fields []Field, constraints *Constraint) string {
+	if constraints == nil {
+		constraints = &Constraint{}
+	}
+	indices := make(map[string][]string)
+	for _, index := range constraints.Indices {
+		name := index.Name
+		if name == "" {
+			name = "idx_" + table + "_" + strings.Join(index.Columns, "_")
+		}
+		tag := "index:" + name
+		if index.Unique {
+			tag = "uniqueIndex:" + name
+		}
+		for _, column := range index.Columns {
+			indices[column] = append(indices[column], tag)
+		}
+	}
+	var body strings.Builder
+	imports := make(map[string]bool)
+	for _, field := range fields {
+		typ := goType(field)
+		goImport(imports, typ)
+		tags := []string{"column:" + field.Name}
+		if field.DataType != "" {
+			tags = append(tags, "type:"+goColumnType(field))
+		}
+		if strings.ToUpper(field.Key) == "PRI" || contains(constraints.PrimaryKeys, field.Name) {
+			tags = append(tags, "primaryKey")
+		}
+		if strings.ToUpper(field.Extra) == "AUTO_INCREMENT" {
+			tags = append(tags, "autoIncrement")
+		}
+		if strings.ToUpper(field.IsNullable) == "NO" {
+			tags = append(tags, "not null")
+		}
+		if field.Default != nil {
+			tags = append(tags, fmt.Sprintf("default:%v", field.Default))
+		}
+		if field.Comment != "" {
+			tags = append(tags, "comment:"+strings.ReplaceAll(field.Comment, ";", ","))
+		}
+		tags = append(tags, indices[field.Name]...)
+		body.WriteString(fmt.Sprintf("\t%s %s `gorm:%q json:%q`\n", goName(field.Name), typ, strings.Join(tags, ";"), field.Name))
+	}
+	structName := goName(table)
+	decl := fmt.Sprintf("type %s struct {\n%s}\n\n", structName, body.String())
+	decl += fmt.Sprintf("func (%s) TableName() string {\n\treturn %q\n}\n", structName, table)
+	return goSource(imports, decl)
+}
+
+// GenerateGoStruct generates a plain Go struct for table with db and json
+// tags, for use with sqlx style scanning rather than GORM.
+func GenerateGoStruct(table string, fields []Field) string {
+	var body strings.Builder
+	imports := make(map[string]bool)
+	for _, field := range fields {
+		typ := goType(field)
+		goImport(imports, typ)
+		body.WriteString(fmt.Sprintf("\t%s %s `db:%q json:%q`\n", goName(field.Name), typ, field.Name, field.Name))
+	}
+	return goSource(imports, fmt.Sprintf("type %s struct {\n%s}\n", goName(table), body.String()))
+}
+
+// goImport adds the package needed by the Go type typ to imports.
+func goImport(imports map[string]bool, typ string) {
+	switch {
+	case strings.Contains(typ, "time."):
+		imports["time"] = true
+	case strings.Contains(typ, "json."):
+		imports["encoding/json"] = true
+	}
+}
+
+// goSource prefixes decl with an import block and formats it.
+func goSource(imports map[string]bool, decl string) string {
+	var sb strings.Builder
+	if len(imports) > 0 {
+		var paths []string
+		for path := range imports {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		sb.WriteString("import (\n")
+		for _, path := range paths {
+			sb.WriteString(fmt.Sprintf("\t%q\n", path))
+		}
+		sb.WriteString(")\n\n")
+	}
+	sb.WriteString(decl)
+	src, err := format.Source([]byte(sb.String()))
+	if err != nil {
+		return sb.String()
+	}
+	return string(src)
+}
+
+func goColumnType(f Field) string {
+	switch {
+	case f.Length > 0 && f.Precision > 0:
+		return fmt.Sprintf("%s(%d,%d)", f.DataType, f.Length, f.Precision)
+	case f.Length > 0 && contains([]string{"varchar", "char", "string", "character varying", "character"}, strings.ToLower(f.DataType)):
+		return fmt.Sprintf("%s(%d)", f.DataType, f.Length)
+	}
+	return f.DataType
+}
+
+var dotEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "{", `\{`, "}", `\}`, "|", `\|`, "<", `\<`, ">", `\>`)
+
+// ToDOT renders tables as a Graphviz digraph, with a record node listing the
+// columns of each table and an edge from a table to every table its foreign
+// keys, keyed by table name in fks, reference.
+func ToDOT(tables []SourceFields, fks map[string][]ForeignKey) string {
+	var sb strings.Builder
+	sb.WriteString("digraph schema {\n")
+	sb.WriteString("\trankdir=LR;\n")
+	sb.WriteString("\tnode [shape=record];\n")
+	for _, table := range tables {
+		columns := make([]string, len(table.Fields))
+		for i, field := range table.Fields {
+			column := field.Name + " : " + goColumnType(field)
+			if strings.ToUpper(field.Key) == "PRI" {
+				column += " (PK)"
+			}
+			columns[i] = dotEscaper.Replace(column) + `\l`
+		}
+		sb.WriteString(fmt.Sprintf("\t%q [label=\"{%s|%s}\"];\n", table.Name, dotEscaper.Replace(table.Name), strings.Join(columns, "")))
+	}
+	var names []string
+	for name := range fks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, fk := range fks[name] {
+			label := strings.Join(fk.Columns, ", ") + " -> " + strings.Join(fk.ReferencedColumns, ", ")
+			sb.WriteString(fmt.Sprintf("\t%q -> %q [label=%q];\n", name, fk.ReferencedTable, label))
+		}
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
@@ -0,0 +1,93 @@
+package metadata
+
+import "testing"
+
+func TestGoType(t *testing.T) {
+	tests := []struct {
+		field Field
+		want  string
+	}{
+		{field: Field{DataType: "int"}, want: "int64"},
+		{field: Field{DataType: "INTEGER"}, want: "int64"},
+		{field: Field{DataType: "serial"}, want: "int64"},
+		{field: Field{DataType: "bigint"}, want: "int64"},
+		{field: Field{DataType: "smallint"}, want: "int16"},
+		{field: Field{DataType: "tinyint", Length: 1}, want: "bool"},
+		{field: Field{DataType: "varchar"}, want: "string"},
+		{field: Field{DataType: "numeric"}, want: "float64"},
+		{field: Field{DataType: "timestamp"}, want: "time.Time"},
+		{field: Field{DataType: "int", IsNullable: "YES"}, want: "*int64"},
+		{field: Field{DataType: "jsonb", IsNullable: "YES"}, want: "json.RawMessage"},
+		{field: Field{DataType: "bytea"}, want: "[]byte"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.field.DataType+tt.field.IsNullable, func(t *testing.T) {
+			if got := goType(tt.field); got != tt.want {
+				t.Fatalf("goType(%+v) = %q, want %q", tt.field, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateGoStruct(t *testing.T) {
+	fields := []Field{
+		{Name: "id", DataType: "int", IsNullable: "NO"},
+		{Name: "email", DataType: "varchar", IsNullable: "NO"},
+		{Name: "active", DataType: "bool", IsNullable: "NO"},
+		{Name: "balance", DataType: "numeric", IsNullable: "NO"},
+		{Name: "deleted_at", DataType: "timestamp", IsNullable: "YES"},
+	}
+	want := "import (\n" +
+		"\t\"time\"\n" +
+		")\n\n" +
+		"type UserAccounts struct {\n" +
+		"\tID        int64      `db:\"id\" json:\"id\"`\n" +
+		"\tEmail     string     `db:\"email\" json:\"email\"`\n" +
+		"\tActive    bool       `db:\"active\" json:\"active\"`\n" +
+		"\tBalance   float64    `db:\"balance\" json:\"balance\"`\n" +
+		"\tDeletedAt *time.Time `db:\"deleted_at\" json:\"deleted_at\"`\n" +
+		"}\n"
+	if got := GenerateGoStruct("user_accounts", fields); got != want {
+		t.Fatalf("GenerateGoStruct() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestToGoStruct(t *testing.T) {
+	fields := []Field{
+		{Name: "id", DataType: "int", Key: "PRI", Extra: "auto_increment", IsNullable: "NO"},
+		{Name: "email", DataType: "varchar", Length: 255, IsNullable: "NO"},
+		{Name: "status", DataType: "varchar", Length: 16, IsNullable: "YES", Default: "active", Comment: "state; lifecycle"},
+	}
+	constraints := &Constraint{Indices: []Indices{{Name: "users_email_key", Unique: true, Columns: []string{"email"}}}}
+	want := "type Users struct {\n" +
+		"\tID     int64   `gorm:\"column:id;type:int;primaryKey;autoIncrement;not null\" json:\"id\"`\n" +
+		"\tEmail  string  `gorm:\"column:email;type:varchar(255);not null;uniqueIndex:users_email_key\" json:\"email\"`\n" +
+		"\tStatus *string `gorm:\"column:status;type:varchar(16);default:active;comment:state, lifecycle\" json:\"status\"`\n" +
+		"}\n\n" +
+		"func (Users) TableName() string {\n" +
+		"\treturn \"users\"\n" +
+		"}\n"
+	if got := ToGoStruct("users", fields, constraints); got != want {
+		t.Fatalf("ToGoStruct() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestToDOT(t *testing.T) {
+	tables := []SourceFields{
+		{Name: "users", Fields: []Field{{Name: "id", DataType: "int", Key: "PRI"}, {Name: "email", DataType: "varchar", Length: 255}}},
+		{Name: "orders", Fields: []Field{{Name: "id", DataType: "int", Key: "PRI"}, {Name: "user_id", DataType: "int"}, {Name: "note|x", DataType: "text"}}},
+	}
+	fks := map[string][]ForeignKey{
+		"orders": {{Name: "orders_user_fk", Columns: []string{"user_id"}, ReferencedTable: "users", ReferencedColumns: []string{"id"}}},
+	}
+	want := "digraph schema {\n" +
+		"\trankdir=LR;\n" +
+		"\tnode [shape=record];\n" +
+		"\t\"users\" [label=\"{users|id : int (PK)\\l" + "email : varchar(255)\\l}\"];\n" +
+		"\t\"orders\" [label=\"{orders|id : int (PK)\\l" + "user_id : int\\l" + "note\\|x : text\\l}\"];\n" +
+		"\t\"orders\" -> \"users\" [label=\"user_id -> id\"];\n" +
+		"}\n"
+	if got := ToDOT(tables, fks); got != want {
+		t.Fatalf("ToDOT() =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,405 @@
+package metadata
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/oarkflow/errors"
+	"github.com/oarkflow/squealx"
+	"github.com/oarkflow/squealx/dbresolver"
+)
+
+type DataMigrationOptions struct {
+	// BatchSize is the number of rows read and inserted at once. Defaults to 100.
+	BatchSize int `json:"batch_size"`
+	// CommitEvery commits the running transaction and starts a new one after
+	// this many batches. When zero all rows are committed at once at the end.
+	CommitEvery int `json:"commit_every"`
+	// Offset is the number of source rows already copied by a previous run,
+	// used to resume a migration that failed midway.
+	Offset int `json:"offset"`
+}
+
+// MigrateData copies the rows of src into dest. It returns the offset of the
+// last committed row, which can be passed back as DataMigrationOptions.Offset
+// to resume after a failure.
+func MigrateData(srcCon, destCon DataSource, src, dest string, opts ...DataMigrationOptions) (int, error) {
+	var opt DataMigrationOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	if opt.BatchSize <= 0 {
+		opt.BatchSize = 100
+	}
+	if dest == "" {
+		dest = src
+	}
+	committed := opt.Offset
+	if err := validateIdentifiers(src, dest); err != nil {
+		return committed, err
+	}
+	err := connect(srcCon, destCon)
+	if err != nil {
+		return committed, err
+	}
+	client, ok := destCon.Client().(dbresolver.DBResolver)
+	if !ok {
+		return committed, errors.New("data migration requires a SQL destination")
+	}
+	fields, err := srcCon.GetFields(src)
+	if err != nil {
+		return committed, errors.NewE(err, fmt.Sprintf("Unable to get fields for %s", src), "MigrateData")
+	}
+	destFields, err := destCon.GetFields(dest)
+	if err != nil {
+		return committed, errors.NewE(err, fmt.Sprintf("Unable to get fields for %s", dest), "MigrateData")
+	}
+	for _, field := range fields {
+		if err := validateIdentifier(field.Name); err != nil {
+			return committed, err
+		}
+	}
+	orderBy := pageOrder(fields)
+	tx, err := client.Beginx()
+	if err != nil {
+		return committed, err
+	}
+	offset := opt.Offset
+	batches := 0
+	for {
+		rows, err := srcCon.GetRawCollection(pageQuery(srcCon.GetType(), "SELECT * FROM "+quoteIdentifier(srcCon.GetType(), src), orderBy, opt.BatchSize, offset))
+		if err != nil {
+			_ = tx.Rollback()
+			return committed, err
+		}
+		if len(rows) == 0 {
+			break
+		}
+		coerceRows(rows, destFields)
+		_, err = tx.NamedExec(insertQuery(destCon.GetType(), dest, rows), rows)
+		if err != nil {
+			_ = tx.Rollback()
+			return committed, errors.NewE(err, fmt.Sprintf("Unable to copy rows into %s", dest), "MigrateData")
+		}
+		offset += len(rows)
+		batches++
+		if opt.CommitEvery > 0 && batches%opt.CommitEvery == 0 {
+			if err := tx.Commit(); err != nil {
+				return committed, err
+			}
+			// The rows are committed even if the next transaction can't begin,
+			// so a resumed migration doesn't copy them again.
+			committed = offset
+			if tx, err = client.Beginx(); err != nil {
+				return committed, err
+			}
+		}
+		if len(rows) < opt.BatchSize {
+			break
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return committed, err
+	}
+	return offset, nil
+}
+
+type BatchOptions struct {
+	// Size is the number of rows inserted per statement. Defaults to 100.
+	Size int `json:"size"`
+	// Concurrency is the number of batches inserted at once, each worker on
+	// its own connection. Defaults to 1, inserting batches one after another.
+	Concurrency int `json:"concurrency"`
+	// Transaction inserts all batches in a single transaction, rolled back
+	// when any batch fails. A transaction is bound to one connection, so it
+	// can't be combined with a Concurrency above 1.
+	Transaction bool `json:"transaction"`
+}
+
+// StoreInBatchesWithOptions is StoreInBatches with options to insert batches
+// concurrently and in transactions.
+func StoreInBatchesWithOptions(con DataSource, table string, val any, opts BatchOptions) error {
+	if err := validateInsert(table, val); err != nil {
+		return err
+	}
+	if opts.Size <= 0 {
+		opts.Size = 100
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 1
+	}
+	if opts.Transaction && opts.Concurrency > 1 {
+		return errors.New("batch insert can't run in a transaction with a concurrency above 1")
+	}
+	client, ok := con.Client().(dbresolver.DBResolver)
+	if !ok {
+		return errors.New("batch insert requires a SQL data source")
+	}
+	sliceValue := reflect.ValueOf(val)
+	if sliceValue.Kind() != reflect.Slice {
+		return nil
+	}
+	// The transaction is opened before any worker starts, so failing to open
+	// it leaves nothing running.
+	var tx *squealx.Tx
+	if opts.Transaction {
+		var err error
+		if tx, err = client.Beginx(); err != nil {
+			return err
+		}
+	}
+	jobs := make(chan []any)
+	errs := make([]error, opts.Concurrency)
+	var failed atomic.Bool
+	var wg sync.WaitGroup
+	for w := 0; w < opts.Concurrency; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for batchData := range jobs {
+				if failed.Load() {
+					continue
+				}
+				var err error
+				if tx != nil {
+					_, err = tx.NamedExec(insertQuery(con.GetType(), table, batchData), batchData)
+				} else {
+					_, err = client.Exec(insertQuery(con.GetType(), table, batchData), batchData)
+				}
+				if err != nil {
+					errs[w] = err
+					failed.Store(true)
+				}
+			}
+		}(w)
+	}
+	for _, batchData := range batches(sliceValue, opts.Size) {
+		if failed.Load() {
+			break
+		}
+		jobs <- batchData
+	}
+	close(jobs)
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			if tx != nil {
+				_ = tx.Rollback()
+			}
+			return errors.NewE(err, fmt.Sprintf("Unable to insert into %s", table), "StoreInBatches")
+		}
+	}
+	if tx != nil {
+		return tx.Commit()
+	}
+	return nil
+}
+
+// eachRow runs query and calls fn with each row as it's read from the driver,
+// so only one row is held in memory at a time. An error from fn stops the
+// iteration and is returned.
+func eachRow(client dbresolver.DBResolver, query string, fn func(row map[string]any) error) error {
+	rows, err := client.Queryx(query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		row := make(map[string]any)
+		if err := rows.MapScan(row); err != nil {
+			return err
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// coerceRows converts the values of rows read from one dialect to the types of
+// the destination columns where drivers disagree: booleans stored as integers
+// by MySQL and text returned as bytes.
+func coerceRows(rows []map[string]any, fields []Field) {
+	types := make(map[string]string)
+	for _, field := range fields {
+		types[field.Name] = strings.ToLower(field.DataType)
+	}
+	for _, row := range rows {
+		for name, val := range row {
+			dataType, ok := types[name]
+			if !ok || val == nil {
+				continue
+			}
+			if b, ok := val.([]byte); ok && dataType != "bytea" && !strings.Contains(dataType, "blob") && !strings.Contains(dataType, "binary") {
+				val = string(b)
+				row[name] = val
+			}
+			switch dataType {
+			case "bool", "boolean":
+				switch v := val.(type) {
+				case int64:
+					row[name] = v != 0
+				case string:
+					if i, err := strconv.ParseInt(v, 10, 64); err == nil {
+						row[name] = i != 0
+					}
+				}
+			case "tinyint", "smallint", "int", "integer", "bigint":
+				if v, ok := val.(bool); ok {
+					if v {
+						row[name] = 1
+					} else {
+						row[name] = 0
+					}
+				}
+			}
+		}
+	}
+}
+
+// pageOrder returns the columns the rows of a table with fields are paged in
+// order of: its primary key, or all its columns when it has none so the pages
+// still follow one order.
+func pageOrder(fields []Field) []string {
+	var orderBy []string
+	for _, field := range fields {
+		if strings.ToUpper(field.Key) == "PRI" {
+			orderBy = append(orderBy, field.Name)
+		}
+	}
+	if len(orderBy) == 0 {
+		for _, field := range fields {
+			orderBy = append(orderBy, field.Name)
+		}
+	}
+	return orderBy
+}
+
+// pageQuery limits query to a window of rows for the given dialect, ordered by
+// the orderBy columns so consecutive windows don't overlap.
+func pageQuery(dialect, query string, orderBy []string, limit, offset int) string {
+	order := ""
+	if len(orderBy) > 0 {
+		order = " ORDER BY " + strings.Join(quoteIdentifiers(dialect, orderBy), ", ")
+	}
+	switch dialect {
+	case "mssql":
+		if order == "" {
+			order = " ORDER BY (SELECT NULL)"
+		}
+		return fmt.Sprintf("%s%s OFFSET %d ROWS FETCH NEXT %d ROWS ONLY", query, order, offset, limit)
+	default:
+		return fmt.Sprintf("%s%s LIMIT %d OFFSET %d", query, order, limit, offset)
+	}
+}
+
+// Resequence renumbers the integer surrogate key pkColumn of table to 1..n in
+// its current order, removing gaps, and updates the columns of other tables
+// referencing it. It runs in a single transaction with foreign key checks
+// suspended, which on Postgres requires superuser rights. Only MySQL and
+// Postgres are supported.
+func Resequence(con DataSource, table, pkColumn string) error {
+	if err := validateIdentifiers(table, pkColumn); err != nil {
+		return err
+	}
+	dialect := con.GetType()
+	var disableChecks, enableChecks string
+	switch dialect {
+	case "mysql":
+		disableChecks, enableChecks = "SET FOREIGN_KEY_CHECKS = 0", "SET FOREIGN_KEY_CHECKS = 1"
+	case "postgres":
+		disableChecks = "SET LOCAL session_replication_role = replica"
+	default:
+		return fmt.Errorf("resequencing is not supported for %s", con.GetType())
+	}
+	foreignKeys, err := con.GetReferencingForeignKeys(table)
+	if err != nil {
+		return err
+	}
+	var references []ForeignKey
+	for _, fk := range foreignKeys {
+		if len(fk.ReferencedColumns) == 1 && fk.ReferencedColumns[0] == pkColumn {
+			references = append(references, fk)
+		}
+	}
+	tx, err := con.Begin()
+	if err != nil {
+		return err
+	}
+	ids, err := sequenceIDs(tx, dialect, table, pkColumn)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if len(ids) > 0 && ids[0] < 1 {
+		_ = tx.Rollback()
+		return fmt.Errorf("unable to resequence %s: %s holds values below 1", table, pkColumn)
+	}
+	if _, err = tx.Exec(disableChecks); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	// Ids are renumbered in ascending order, so an id only ever moves down
+	// onto a value that has already been vacated.
+	for i, id := range ids {
+		seq := int64(i + 1)
+		if seq == id {
+			continue
+		}
+		statements := []string{fmt.Sprintf("UPDATE %s SET %s = %d WHERE %s = %d", quoteIdentifier(dialect, table), quoteIdentifier(dialect, pkColumn), seq, quoteIdentifier(dialect, pkColumn), id)}
+		for _, fk := range references {
+			column := quoteIdentifier(dialect, fk.Columns[0])
+			statements = append(statements, fmt.Sprintf("UPDATE %s SET %s = %d WHERE %s = %d", quoteIdentifier(dialect, fk.Table), column, seq, column, id))
+		}
+		for _, statement := range statements {
+			if _, err = tx.Exec(statement); err != nil {
+				_ = tx.Rollback()
+				return errors.NewE(err, fmt.Sprintf("Unable to resequence %s", table), "Resequence")
+			}
+		}
+	}
+	if con.GetType() == "postgres" {
+		_, err = tx.Exec(fmt.Sprintf("SELECT setval(pg_get_serial_sequence('%s', '%s'), %d, %t)", quoteIdentifier(dialect, table), pkColumn, max(len(ids), 1), len(ids) > 0))
+		if err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+	if enableChecks != "" {
+		if _, err = tx.Exec(enableChecks); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	if con.GetType() == "mysql" {
+		// MySQL moves the counter to the current maximum when set below it.
+		_, err = con.Exec(fmt.Sprintf("ALTER TABLE %s AUTO_INCREMENT = 1", quoteIdentifier(dialect, table)))
+		return err
+	}
+	return nil
+}
+
+func sequenceIDs(tx squealx.SQLTx, dialect, table, pkColumn string) ([]int64, error) {
+	column := quoteIdentifier(dialect, pkColumn)
+	rows, err := tx.Query(fmt.Sprintf("SELECT %s FROM %s ORDER BY %s", column, quoteIdentifier(dialect, table), column))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
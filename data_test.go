@@ -0,0 +1,214 @@
+package metadata
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/oarkflow/squealx"
+)
+
+func TestStoreInBatchesWithOptionsRejectsConcurrentTransaction(t *testing.T) {
+	rows := []map[string]any{{"id": 1}, {"id": 2}}
+	err := StoreInBatchesWithOptions(&Postgres{}, "users", rows, BatchOptions{Size: 1, Concurrency: 2, Transaction: true})
+	if err == nil {
+		t.Fatal("expected an error for a transaction with a concurrency above 1")
+	}
+}
+
+func TestPageQuery(t *testing.T) {
+	keyed := []Field{{Name: "id", Key: "PRI"}, {Name: "name"}}
+	keyless := []Field{{Name: "order"}, {Name: "qty"}}
+	tests := []struct {
+		name    string
+		dialect string
+		fields  []Field
+		want    string
+	}{
+		{name: "mysql key", dialect: "mysql", fields: keyed, want: "SELECT * FROM t ORDER BY `id` LIMIT 10 OFFSET 20"},
+		{name: "postgres keyless", dialect: "postgres", fields: keyless, want: `SELECT * FROM t ORDER BY "order", "qty" LIMIT 10 OFFSET 20`},
+		{name: "mssql key", dialect: "mssql", fields: keyed, want: "SELECT * FROM t ORDER BY [id] OFFSET 20 ROWS FETCH NEXT 10 ROWS ONLY"},
+		{name: "mssql no columns", dialect: "mssql", want: "SELECT * FROM t ORDER BY (SELECT NULL) OFFSET 20 ROWS FETCH NEXT 10 ROWS ONLY"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := pageQuery(tt.dialect, "SELECT * FROM t", pageOrder(tt.fields), 10, 20); got != tt.want {
+				t.Fatalf("pageQuery() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// countingConnector is a database/sql connector whose statements succeed
+// without a server, counting how many are prepared.
+type countingConnector struct {
+	prepares atomic.Int64
+	begins   atomic.Int64
+	// maxBegins fails the transactions begun after the first maxBegins, when
+	// set.
+	maxBegins int64
+	mu        sync.Mutex
+	queries   map[string]bool
+}
+
+func (c *countingConnector) Connect(context.Context) (driver.Conn, error) {
+	return countingConn{c}, nil
+}
+
+func (c *countingConnector) Driver() driver.Driver {
+	return countingDriver{}
+}
+
+type countingDriver struct{}
+
+func (countingDriver) Open(string) (driver.Conn, error) {
+	return nil, errors.New("open through the connector")
+}
+
+type countingConn struct {
+	c *countingConnector
+}
+
+func (conn countingConn) Prepare(query string) (driver.Stmt, error) {
+	conn.c.prepares.Add(1)
+	conn.c.mu.Lock()
+	defer conn.c.mu.Unlock()
+	if conn.c.queries == nil {
+		conn.c.queries = make(map[string]bool)
+	}
+	conn.c.queries[query] = true
+	return countingStmt{}, nil
+}
+
+func (countingConn) Close() error { return nil }
+
+func (conn countingConn) Begin() (driver.Tx, error) {
+	if n := conn.c.begins.Add(1); conn.c.maxBegins > 0 && n > conn.c.maxBegins {
+		return nil, errors.New("begin failed")
+	}
+	return countingTx{}, nil
+}
+
+type countingStmt struct{}
+
+func (countingStmt) Close() error  { return nil }
+func (countingStmt) NumInput() int { return -1 }
+
+func (countingStmt) Exec([]driver.Value) (driver.Result, error) {
+	return driver.RowsAffected(1), nil
+}
+
+func (countingStmt) Query([]driver.Value) (driver.Rows, error) {
+	return nil, errors.New("not supported")
+}
+
+type countingTx struct{}
+
+func (countingTx) Commit() error   { return nil }
+func (countingTx) Rollback() error { return nil }
+
+// countingSource returns a MySQL data source on c. A single connection keeps
+// database/sql from preparing a statement again on a new connection.
+func countingSource(c *countingConnector) DataSource {
+	db := sql.OpenDB(c)
+	db.SetMaxOpenConns(1)
+	return NewFromDB(squealx.NewDb(db, "mysql", "counting"))
+}
+
+func batchRows(n int) []map[string]any {
+	rows := make([]map[string]any, n)
+	for i := range rows {
+		rows[i] = map[string]any{"id": i, "name": "user"}
+	}
+	return rows
+}
+
+func TestStoreInBatchesReusesStatements(t *testing.T) {
+	tests := []struct {
+		name    string
+		rows    int
+		queries int
+	}{
+		{name: "full batches", rows: 300, queries: 1},
+		{name: "smaller last batch", rows: 350, queries: 2},
+	}
+	var prepares []int64
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &countingConnector{}
+			if err := countingSource(c).StoreInBatches("users", batchRows(tt.rows), 100); err != nil {
+				t.Fatal(err)
+			}
+			if got := len(c.queries); got != tt.queries {
+				t.Fatalf("prepared %d distinct queries, want %d", got, tt.queries)
+			}
+			prepares = append(prepares, c.prepares.Load()/int64(tt.queries))
+		})
+	}
+	// The resolver may prepare a query on more than one pool, but never
+	// again for each batch.
+	c := &countingConnector{}
+	if err := countingSource(c).StoreInBatches("users", batchRows(1000), 100); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.prepares.Load(); len(prepares) == 0 || got != prepares[0] {
+		t.Fatalf("prepared %d statements for 10 batches, want %v as for 3", got, prepares)
+	}
+}
+
+// BenchmarkStoreInBatches measures building and binding 10k rows in batches
+// of 100. The counting driver stands in for a database, so the statement
+// parse a server would do for every batch without the prepared statement
+// isn't part of the measurement, only the number of prepares is reported.
+func BenchmarkStoreInBatches(b *testing.B) {
+	rows := batchRows(10000)
+	c := &countingConnector{}
+	con := countingSource(c)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := con.StoreInBatches("users", rows, 100); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(c.prepares.Load())/float64(b.N), "prepares/op")
+}
+
+// pagedSource serves rows as successive pages of GetRawCollection.
+type pagedSource struct {
+	*fakeSource
+	rows []map[string]any
+	size int
+}
+
+func (p *pagedSource) GetRawCollection(string, ...map[string]any) ([]map[string]any, error) {
+	page := p.rows
+	if len(page) > p.size {
+		page = page[:p.size]
+	}
+	p.rows = p.rows[len(page):]
+	return page, nil
+}
+
+func TestMigrateDataReportsCommittedRowsWhenBeginFails(t *testing.T) {
+	fields := []Field{{Name: "id", DataType: "int", Key: "PRI"}, {Name: "name", DataType: "varchar"}}
+	src := &pagedSource{
+		fakeSource: &fakeSource{dialect: "mysql", fields: map[string][]Field{"users": fields}},
+		rows:       batchRows(5),
+		size:       2,
+	}
+	c := &countingConnector{maxBegins: 1}
+	dest := countingSource(c).(*MySQL)
+	dest.cache = newSchemaCache()
+	dest.cache.setFields(dest.GetDBName(), "users", fields)
+	committed, err := MigrateData(src, dest, "users", "users", DataMigrationOptions{BatchSize: 2, CommitEvery: 1})
+	if err == nil {
+		t.Fatal("expected the failed begin to be returned")
+	}
+	if committed != 2 {
+		t.Fatalf("committed = %d, want the 2 rows of the committed batch", committed)
+	}
+}
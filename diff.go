@@ -0,0 +1,139 @@
+package metadata
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/oarkflow/errors"
+)
+
+// SchemaDiff lists the differences between the tables of two data sources,
+// from the first to the second.
+type SchemaDiff struct {
+	AddedTables   []string    `json:"added_tables,omitempty"`
+	RemovedTables []string    `json:"removed_tables,omitempty"`
+	Tables        []TableDiff `json:"tables,omitempty"`
+}
+
+// TableDiff lists the column differences of a table present in both data sources.
+type TableDiff struct {
+	Table   string        `json:"table"`
+	Added   []Field       `json:"added,omitempty"`
+	Removed []Field       `json:"removed,omitempty"`
+	Changed []FieldChange `json:"changed,omitempty"`
+}
+
+// FieldChange is a column whose definition differs between the data sources.
+type FieldChange struct {
+	Old Field `json:"old"`
+	New Field `json:"new"`
+}
+
+// Empty reports whether the data sources have the same tables and columns.
+func (d SchemaDiff) Empty() bool {
+	return len(d.AddedTables) == 0 && len(d.RemovedTables) == 0 && len(d.Tables) == 0
+}
+
+// DiffSchemas compares the tables of a and b and returns what changed from a
+// to b. Columns are compared the way GenerateSQL would when altering a
+// table of a, so a column is reported as changed only when GenerateSQL would
+// alter it.
+func DiffSchemas(a, b DataSource) (SchemaDiff, error) {
+	var diff SchemaDiff
+	if err := connect(a, b); err != nil {
+		return diff, err
+	}
+	oldTables, err := tableNames(a)
+	if err != nil {
+		return diff, err
+	}
+	newTables, err := tableNames(b)
+	if err != nil {
+		return diff, err
+	}
+	for _, table := range newTables {
+		if !contains(oldTables, table) {
+			diff.AddedTables = append(diff.AddedTables, table)
+		}
+	}
+	for _, table := range oldTables {
+		if !contains(newTables, table) {
+			diff.RemovedTables = append(diff.RemovedTables, table)
+			continue
+		}
+		oldFields, err := a.GetFields(table)
+		if err != nil {
+			return diff, errors.NewE(err, fmt.Sprintf("Unable to get fields for %s", table), "DiffSchemas")
+		}
+		newFields, err := b.GetFields(table)
+		if err != nil {
+			return diff, errors.NewE(err, fmt.Sprintf("Unable to get fields for %s", table), "DiffSchemas")
+		}
+		if tableDiff := diffFields(a.GetType(), table, oldFields, newFields); tableDiff != nil {
+			diff.Tables = append(diff.Tables, *tableDiff)
+		}
+	}
+	return diff, nil
+}
+
+func tableNames(con DataSource) ([]string, error) {
+	tables, err := con.GetTables()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(tables))
+	for i, table := range tables {
+		names[i] = table.Name
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// diffFields compares the columns of table, returning nil when they're the same.
+func diffFields(dialect, table string, oldFields, newFields []Field) *TableDiff {
+	diff := TableDiff{Table: table}
+	existing := make(map[string]Field)
+	for _, field := range oldFields {
+		existing[field.Name] = field
+	}
+	seen := make(map[string]bool)
+	for _, field := range newFields {
+		seen[field.Name] = true
+		old, ok := existing[field.Name]
+		if !ok {
+			diff.Added = append(diff.Added, field)
+			continue
+		}
+		if !fieldsEqual(dialect, old, field) {
+			diff.Changed = append(diff.Changed, FieldChange{Old: old, New: field})
+		}
+	}
+	for _, field := range oldFields {
+		if !seen[field.Name] {
+			diff.Removed = append(diff.Removed, field)
+		}
+	}
+	if len(diff.Added) == 0 && len(diff.Removed) == 0 && len(diff.Changed) == 0 {
+		return nil
+	}
+	return &diff
+}
+
+// fieldsEqual compares two columns with the comparator of dialect, plus their
+// nullability.
+func fieldsEqual(dialect string, existing, f Field) bool {
+	if !strings.EqualFold(existing.IsNullable, f.IsNullable) {
+		return false
+	}
+	switch dialect {
+	case "mysql":
+		return mysqlFieldsEqual(existing, f)
+	case "postgres":
+		return postgresFieldsEqual(existing, f)
+	}
+	return strings.EqualFold(existing.DataType, f.DataType) &&
+		existing.Length == f.Length &&
+		existing.Precision == f.Precision &&
+		defaultsEqual(existing.Default, f.Default)
+}
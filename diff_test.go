@@ -0,0 +1,54 @@
+package metadata
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffSchemas(t *testing.T) {
+	a := &fakeSource{
+		dialect: "postgres",
+		fields: map[string][]Field{
+			"users": {
+				{Name: "id", DataType: "integer", IsNullable: "NO", Default: "nextval('users_id_seq'::regclass)"},
+				{Name: "name", DataType: "character varying", Length: 50, IsNullable: "YES"},
+				{Name: "legacy", DataType: "text", IsNullable: "YES"},
+			},
+			"logs": {{Name: "id", DataType: "bigint", IsNullable: "NO"}},
+		},
+	}
+	b := &fakeSource{
+		dialect: "postgres",
+		fields: map[string][]Field{
+			"users": {
+				{Name: "id", DataType: "integer", IsNullable: "NO", Default: "nextval('users_id_seq'::regclass)"},
+				{Name: "name", DataType: "text", IsNullable: "YES"},
+				{Name: "email", DataType: "character varying", Length: 255, IsNullable: "NO"},
+			},
+			"orders": {{Name: "id", DataType: "bigint", IsNullable: "NO"}},
+		},
+	}
+	diff, err := DiffSchemas(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(diff.AddedTables, []string{"orders"}) || !reflect.DeepEqual(diff.RemovedTables, []string{"logs"}) {
+		t.Fatalf("added %v and removed %v tables, want [orders] and [logs]", diff.AddedTables, diff.RemovedTables)
+	}
+	if len(diff.Tables) != 1 || diff.Tables[0].Table != "users" {
+		t.Fatalf("expected a diff of users only, got %+v", diff.Tables)
+	}
+	users := diff.Tables[0]
+	if len(users.Added) != 1 || users.Added[0].Name != "email" {
+		t.Fatalf("added columns %+v, want email", users.Added)
+	}
+	if len(users.Removed) != 1 || users.Removed[0].Name != "legacy" {
+		t.Fatalf("removed columns %+v, want legacy", users.Removed)
+	}
+	if len(users.Changed) != 1 || users.Changed[0].Old.DataType != "character varying" || users.Changed[0].New.DataType != "text" {
+		t.Fatalf("changed columns %+v, want name from character varying to text", users.Changed)
+	}
+	if same, err := DiffSchemas(a, a); err != nil || !same.Empty() {
+		t.Fatalf("DiffSchemas(a, a) = %+v, %v, want empty", same, err)
+	}
+}
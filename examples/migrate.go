@@ -116,7 +116,7 @@ func main() {
 	/*sqlParts := strings.Split(sql, ";")
 	for _, sq := range sqlParts {
 		if sq != "" {
-			err = connector.Exec(sq)
+			_, err = connector.Exec(sq)
 			if err != nil {
 				fmt.Println(sq)
 				panic(err)
@@ -0,0 +1,69 @@
+package metadata
+
+import (
+	"fmt"
+	"strings"
+)
+
+// fakeSource is an in-memory DataSource for the functions that only need the
+// schema of a data source. Methods it doesn't override panic.
+type fakeSource struct {
+	DataSource
+	dialect      string
+	transactions bool
+	fields       map[string][]Field
+	foreignKeys  map[string][]ForeignKey
+	checks       map[string][]Check
+	views        []Source
+	execErr      error
+	executed     []string
+}
+
+func (f *fakeSource) Connect() (DataSource, error) {
+	return f, nil
+}
+
+func (f *fakeSource) GetType() string {
+	return f.dialect
+}
+
+func (f *fakeSource) Capabilities() Capability {
+	return Capability{TransactionalDDL: f.transactions}
+}
+
+func (f *fakeSource) GetTables(database ...string) ([]Source, error) {
+	var tables []Source
+	for name := range f.fields {
+		tables = append(tables, Source{Name: name, Type: "BASE TABLE"})
+	}
+	return tables, nil
+}
+
+func (f *fakeSource) GetViews(database ...string) ([]Source, error) {
+	return f.views, nil
+}
+
+func (f *fakeSource) GetFields(table string, database ...string) ([]Field, error) {
+	return f.fields[table], nil
+}
+
+func (f *fakeSource) GetForeignKeys(table string, database ...string) ([]ForeignKey, error) {
+	return f.foreignKeys[table], nil
+}
+
+func (f *fakeSource) GetConstraints(table string) (*Constraint, error) {
+	return newConstraint(f.fields[table], nil, f.foreignKeys[table], f.checks[table]), nil
+}
+
+func (f *fakeSource) GenerateSQL(table string, fields []Field, indices ...Indices) (string, error) {
+	columns := make([]string, len(fields))
+	for i, field := range fields {
+		columns[i] = field.Name + " " + field.DataType
+	}
+	return fmt.Sprintf("CREATE TABLE %s (%s);", table, strings.Join(columns, ", ")), nil
+}
+
+func (f *fakeSource) Exec(sql string, values ...any) (int64, error) {
+	f.executed = append(f.executed, sql)
+	return 0, f.execErr
+}
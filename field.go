@@ -0,0 +1,220 @@
+package metadata
+
+import (
+	"net/mail"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var dateTimeLayouts = []string{
+	time.RFC3339Nano,
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+}
+
+// InferJSONFieldType returns the data type that best describes a decoded JSON value.
+func InferJSONFieldType(val any) string {
+	switch v := val.(type) {
+	case nil:
+		return ""
+	case bool:
+		return "boolean"
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return "bigint"
+	case float32:
+		return inferFloatType(float64(v))
+	case float64:
+		return inferFloatType(v)
+	case time.Time:
+		return "datetime"
+	case string:
+		return inferTypeFromString(v)
+	case map[string]any, []any:
+		return "json"
+	}
+	return "varchar"
+}
+
+func inferFloatType(v float64) string {
+	if v == float64(int64(v)) {
+		return "bigint"
+	}
+	return "double"
+}
+
+func inferTypeFromString(s string) string {
+	if s == "" {
+		return "varchar"
+	}
+	if _, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return "bigint"
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return "double"
+	}
+	switch strings.ToLower(s) {
+	case "true", "false":
+		return "boolean"
+	}
+	if _, err := time.Parse("2006-01-02", s); err == nil {
+		return "date"
+	}
+	for _, layout := range dateTimeLayouts {
+		if _, err := time.Parse(layout, s); err == nil {
+			return "datetime"
+		}
+	}
+	if len(s) > 255 {
+		return "text"
+	}
+	return "varchar"
+}
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+var emailPattern = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+
+// inferStringFormat returns the JSON Schema format of a string value: uuid,
+// email or uri, or an empty string when it has none of them.
+func inferStringFormat(s string) string {
+	switch {
+	case uuidPattern.MatchString(s):
+		return "uuid"
+	case emailPattern.MatchString(s):
+		if addr, err := mail.ParseAddress(s); err == nil && addr.Address == s {
+			return "email"
+		}
+	case strings.Contains(s, "://"):
+		if u, err := url.Parse(s); err == nil && u.Scheme != "" && u.Host != "" && !strings.ContainsAny(s, " \t\n") {
+			return "uri"
+		}
+	}
+	return ""
+}
+
+// mergeFieldTypes widens two inferred types to one that can hold values of both.
+func mergeFieldTypes(a, b string) string {
+	switch {
+	case a == "":
+		return b
+	case b == "", a == b:
+		return a
+	}
+	pair := a + "," + b
+	switch pair {
+	case "bigint,double", "double,bigint":
+		return "double"
+	case "date,datetime", "datetime,date":
+		return "datetime"
+	}
+	if a == "text" || b == "text" || a == "json" || b == "json" {
+		return "text"
+	}
+	return "varchar"
+}
+
+// SuggestFields proposes fields for a sample of already loaded rows. A column is
+// nullable if any sampled row holds a null value or lacks the column entirely.
+// A string column gets a Format when all its sampled values share it. A json
+// column holding objects gets the Fields suggested for those objects.
+func SuggestFields(rows []map[string]any) []Field {
+	types := make(map[string]string)
+	lengths := make(map[string]int)
+	nullable := make(map[string]bool)
+	formats := make(map[string]string)
+	objects := make(map[string][]map[string]any)
+	scalars := make(map[string]bool)
+	for _, row := range rows {
+		for name, val := range row {
+			if _, ok := types[name]; !ok {
+				types[name] = ""
+			}
+			if val == nil {
+				nullable[name] = true
+				continue
+			}
+			types[name] = mergeFieldTypes(types[name], InferJSONFieldType(val))
+			if object, ok := val.(map[string]any); ok {
+				objects[name] = append(objects[name], object)
+			} else {
+				// Fields only describe columns holding nothing but objects.
+				scalars[name] = true
+			}
+			if s, ok := val.(string); ok {
+				if len(s) > lengths[name] {
+					lengths[name] = len(s)
+				}
+				format, seen := formats[name]
+				if !seen || format == inferStringFormat(s) {
+					formats[name] = inferStringFormat(s)
+				} else {
+					formats[name] = ""
+				}
+			} else {
+				formats[name] = ""
+			}
+		}
+	}
+	names := make([]string, 0, len(types))
+	for name := range types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fields := make([]Field, 0, len(names))
+	for _, name := range names {
+		field := Field{
+			Name:       name,
+			DataType:   types[name],
+			IsNullable: "NO",
+		}
+		if field.DataType == "" {
+			field.DataType = "varchar"
+		}
+		for _, row := range rows {
+			if _, ok := row[name]; !ok {
+				nullable[name] = true
+				break
+			}
+		}
+		if nullable[name] {
+			field.IsNullable = "YES"
+		}
+		if field.DataType == "varchar" && lengths[name] > 0 {
+			field.Length = lengths[name]
+		}
+		if field.DataType == "varchar" || field.DataType == "text" {
+			field.Format = formats[name]
+		}
+		if field.DataType == "json" && len(objects[name]) > 0 && !scalars[name] {
+			field.Fields = SuggestFields(objects[name])
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+var textTypes = []string{"string", "varchar", "character varying", "char", "character", "text", "longtext", "mediumtext", "tinytext"}
+
+// NullEmptyStrings replaces empty strings with nil in the columns of rows whose
+// field isn't a text type, so files that spell missing values as empty
+// strings, such as CSV, can be stored in numeric and date columns.
+func NullEmptyStrings(rows []map[string]any, fields []Field) {
+	nonText := make(map[string]bool)
+	for _, field := range fields {
+		if !contains(textTypes, strings.ToLower(field.DataType)) {
+			nonText[field.Name] = true
+		}
+	}
+	for _, row := range rows {
+		for name, val := range row {
+			if s, ok := val.(string); ok && s == "" && nonText[name] {
+				row[name] = nil
+			}
+		}
+	}
+}
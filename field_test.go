@@ -0,0 +1,162 @@
+package metadata
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestInferJSONFieldType(t *testing.T) {
+	tests := []struct {
+		name string
+		val  any
+		want string
+	}{
+		{name: "nil", val: nil, want: ""},
+		{name: "bool", val: true, want: "boolean"},
+		{name: "int", val: 42, want: "bigint"},
+		{name: "whole float", val: 42.0, want: "bigint"},
+		{name: "float", val: 4.2, want: "double"},
+		{name: "numeric string", val: "42", want: "bigint"},
+		{name: "bool string", val: "false", want: "boolean"},
+		{name: "date", val: "2024-02-29", want: "date"},
+		{name: "datetime", val: "2024-02-29T10:00:00Z", want: "datetime"},
+		{name: "string", val: "hello", want: "varchar"},
+		{name: "object", val: map[string]any{"a": 1}, want: "json"},
+		{name: "array", val: []any{1, 2}, want: "json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := InferJSONFieldType(tt.val); got != tt.want {
+				t.Fatalf("InferJSONFieldType(%v) = %q, want %q", tt.val, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSuggestFieldsNested(t *testing.T) {
+	var rows []map[string]any
+	doc := `[
+		{"id": 1, "email": "a@example.com", "address": {"city": "Oslo", "geo": {"lat": 59.91, "lng": 10.75}}},
+		{"id": 2, "email": "b@example.com", "address": {"city": "Bergen", "zip": "5003", "geo": {"lat": 60.39, "lng": 5.32}}},
+		{"id": 3, "email": null, "tags": ["x"]}
+	]`
+	if err := json.Unmarshal([]byte(doc), &rows); err != nil {
+		t.Fatal(err)
+	}
+	fields := fieldsByName(SuggestFields(rows))
+	tests := []struct {
+		path     []string
+		dataType string
+		nullable string
+		format   string
+	}{
+		{path: []string{"id"}, dataType: "bigint", nullable: "NO"},
+		{path: []string{"email"}, dataType: "varchar", nullable: "YES", format: "email"},
+		{path: []string{"address"}, dataType: "json", nullable: "YES"},
+		{path: []string{"address", "city"}, dataType: "varchar", nullable: "NO"},
+		{path: []string{"address", "zip"}, dataType: "bigint", nullable: "YES"},
+		{path: []string{"address", "geo"}, dataType: "json", nullable: "NO"},
+		{path: []string{"address", "geo", "lat"}, dataType: "double", nullable: "NO"},
+		{path: []string{"address", "geo", "lng"}, dataType: "double", nullable: "NO"},
+		{path: []string{"tags"}, dataType: "json", nullable: "YES"},
+	}
+	for _, tt := range tests {
+		field, ok := fields[tt.path[0]]
+		for _, name := range tt.path[1:] {
+			field, ok = fieldsByName(field.Fields)[name]
+		}
+		if !ok {
+			t.Fatalf("no field %v", tt.path)
+		}
+		if field.DataType != tt.dataType || field.IsNullable != tt.nullable || field.Format != tt.format {
+			t.Fatalf("field %v = %+v, want type %s, nullable %s, format %q", tt.path, field, tt.dataType, tt.nullable, tt.format)
+		}
+	}
+	if len(fields["tags"].Fields) != 0 {
+		t.Fatalf("array column got nested fields %+v", fields["tags"].Fields)
+	}
+	schema := AsJsonSchema(SuggestFields(rows), false)
+	geo := schema.Properties["address"].Properties["geo"]
+	if geo == nil || geo.Type != "object" || geo.Properties["lat"].Type != "number" {
+		t.Fatalf("nested schema not rendered: %s", schema.String())
+	}
+}
+
+func fieldsByName(fields []Field) map[string]Field {
+	byName := make(map[string]Field)
+	for _, field := range fields {
+		byName[field.Name] = field
+	}
+	return byName
+}
+
+func TestSuggestFields(t *testing.T) {
+	rows := []map[string]any{
+		{"id": 1, "name": "ada", "score": 1, "joined": "2024-01-02", "note": nil},
+		{"id": 2, "name": "grace hopper", "score": 2.5, "joined": "2024-01-02T10:00:00Z"},
+	}
+	want := []Field{
+		{Name: "id", DataType: "bigint", IsNullable: "NO"},
+		{Name: "joined", DataType: "datetime", IsNullable: "NO"},
+		{Name: "name", DataType: "varchar", IsNullable: "NO", Length: 12},
+		{Name: "note", DataType: "varchar", IsNullable: "YES"},
+		{Name: "score", DataType: "double", IsNullable: "NO"},
+	}
+	got := SuggestFields(rows)
+	if len(got) != len(want) {
+		t.Fatalf("SuggestFields() = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i].Name != want[i].Name || got[i].DataType != want[i].DataType || got[i].IsNullable != want[i].IsNullable || got[i].Length != want[i].Length {
+			t.Fatalf("field %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestInferStringFormat(t *testing.T) {
+	tests := []struct {
+		val  string
+		want string
+	}{
+		{val: "3f2504e0-4f89-11d3-9a0c-0305e82c3301", want: "uuid"},
+		{val: "3F2504E0-4F89-11D3-9A0C-0305E82C3301", want: "uuid"},
+		{val: "ada@example.com", want: "email"},
+		{val: "https://example.com/a?b=c", want: "uri"},
+		{val: "postgres://db.local:5432/app", want: "uri"},
+		{val: "3f2504e0-4f89-11d3-9a0c-0305e82c330"},
+		{val: "3f2504e04f8911d39a0c0305e82c3301"},
+		{val: "ada@example"},
+		{val: "Ada <ada@example.com>"},
+		{val: "ada @example.com"},
+		{val: "example.com/path"},
+		{val: "https:// example.com"},
+		{val: "file:///etc/hosts"},
+		{val: "plain text"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.val, func(t *testing.T) {
+			if got := inferStringFormat(tt.val); got != tt.want {
+				t.Fatalf("inferStringFormat(%q) = %q, want %q", tt.val, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSuggestedFormatsInJsonSchema(t *testing.T) {
+	rows := []map[string]any{
+		{"id": "3f2504e0-4f89-11d3-9a0c-0305e82c3301", "email": "a@example.com", "site": "https://a.example.com", "mixed": "a@example.com"},
+		{"id": "6ba7b810-9dad-11d1-80b4-00c04fd430c8", "email": "b@example.com", "site": "https://b.example.com", "mixed": "https://b.example.com"},
+	}
+	fields := SuggestFields(rows)
+	for _, field := range fields {
+		if field.DataType != "varchar" {
+			t.Fatalf("field %s typed %s, want varchar", field.Name, field.DataType)
+		}
+	}
+	schema := AsJsonSchema(fields, false)
+	for name, format := range map[string]string{"id": "uuid", "email": "email", "site": "uri", "mixed": ""} {
+		if got := schema.Properties[name].Format; got != format {
+			t.Fatalf("format of %s = %q, want %q", name, got, format)
+		}
+	}
+}
@@ -3,6 +3,7 @@ module github.com/oarkflow/metadata
 go 1.22.3
 
 require (
+	github.com/go-sql-driver/mysql v1.8.1
 	github.com/oarkflow/errors v0.0.6
 	github.com/oarkflow/json v0.0.9
 	github.com/oarkflow/protocol v0.0.16
@@ -12,7 +13,6 @@ require (
 require (
 	filippo.io/edwards25519 v1.1.0 // indirect
 	github.com/bytedance/gopkg v0.1.1 // indirect
-	github.com/go-sql-driver/mysql v1.8.1 // indirect
 	github.com/golang-jwt/jwt/v5 v5.2.1 // indirect
 	github.com/golang-sql/civil v0.0.0-20220223132316-b832511892a9 // indirect
 	github.com/golang-sql/sqlexp v0.1.0 // indirect
@@ -26,15 +26,35 @@ func (p *Http) GetForeignKeys(table string, database ...string) (fields []Foreig
 	return nil, nil
 }
 
+func (p *Http) GetReferencingForeignKeys(table string, database ...string) (fields []ForeignKey, err error) {
+	return nil, nil
+}
+
 func (p *Http) GetIndices(table string, database ...string) (fields []Index, err error) {
 	return nil, nil
 }
 
+func (p *Http) GetConstraints(table string) (*Constraint, error) {
+	return &Constraint{}, nil
+}
+
 func (p *Http) Connect() (DataSource, error) {
 	err := p.client.Setup()
 	return p, err
 }
 
+func (p *Http) ReadOnly() (DataSource, error) {
+	return p, nil
+}
+
+func (p *Http) WriteOnly() (DataSource, error) {
+	return p, nil
+}
+
+func (p *Http) Ping() error {
+	return nil
+}
+
 func (p *Http) Close() error {
 	return nil
 }
@@ -75,6 +95,10 @@ func (p *Http) GetTables(database ...string) ([]Source, error) {
 	return nil, nil
 }
 
+func (p *Http) GetTablesMatching(pattern string, database ...string) ([]Source, error) {
+	return nil, nil
+}
+
 func (p *Http) GetViews(database ...string) ([]Source, error) {
 	return nil, nil
 }
@@ -83,10 +107,50 @@ func (p *Http) GetFields(table string, database ...string) ([]Field, error) {
 	return nil, nil
 }
 
+func (p *Http) QueryInto(dest any, query string, params ...map[string]any) error {
+	panic("Implement me")
+}
+
+func (p *Http) EachRow(query string, fn func(row map[string]any) error) error {
+	panic("Implement me")
+}
+
+func (p *Http) EachRowInTable(table string, fn func(row map[string]any) error) error {
+	panic("Implement me")
+}
+
+func (p *Http) InvalidateCache(table string) {}
+
 func (p *Http) Store(table string, val any) error {
 	panic("Implement me")
 }
 
+func (p *Http) StoreReturning(table string, val any, returning []string) (map[string]any, error) {
+	panic("Implement me")
+}
+
+func (p *Http) Upsert(table string, val any, conflictColumns []string) error {
+	panic("Implement me")
+}
+
+func (p *Http) Delete(table string, where map[string]any) (int64, error) {
+	panic("Implement me")
+}
+
+func (p *Http) SetLogger(logger Logger) {}
+
+func (p *Http) Update(table string, set, where map[string]any, opts ...UpdateOptions) (int64, error) {
+	panic("Implement me")
+}
+
+func (p *Http) Truncate(table string, opts ...TruncateOptions) error {
+	panic("Implement me")
+}
+
+func (p *Http) RenameTable(oldName, newName string) error {
+	panic("Implement me")
+}
+
 func (p *Http) StoreInBatches(table string, val any, size int) error {
 	panic("Implement me")
 }
@@ -118,8 +182,8 @@ func (p *Http) GetCollection(table string) ([]map[string]any, error) {
 	return nil, nil
 }
 
-func (p *Http) Exec(sql string, values ...any) error {
-	return nil
+func (p *Http) Exec(sql string, values ...any) (int64, error) {
+	return 0, nil
 }
 
 func (p *Http) DB() (*sql.DB, error) {
@@ -130,6 +194,14 @@ func (p *Http) GetDBName(database ...string) string {
 	return ""
 }
 
+func (p *Http) GetDatabases() ([]string, error) {
+	return nil, nil
+}
+
+func (p *Http) Explain(query string) ([]map[string]any, error) {
+	return nil, nil
+}
+
 func (p *Http) GetRawCollection(query string, params ...map[string]any) ([]map[string]any, error) {
 	// TODO implement me
 	panic("implement me")
@@ -149,6 +221,10 @@ func (p *Http) GetType() string {
 	return "http"
 }
 
+func (p *Http) Capabilities() Capability {
+	return Capability{}
+}
+
 func (p *Http) Config() Config {
 	panic("implement me")
 }
@@ -177,6 +253,10 @@ func (p *Http) GenerateSQL(table string, newFields []Field, indices ...Indices)
 	return "", nil
 }
 
+func (p *Http) RecreateTable(table string, fields []Field, constraints *Constraint) (string, error) {
+	return "", nil
+}
+
 func (p *Http) Migrate(table string, dst DataSource) error {
 	return nil
 }
@@ -0,0 +1,150 @@
+package metadata
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	queryTokens    = regexp.MustCompile(`'(?:[^']|'')*'|[A-Za-z_][A-Za-z0-9_$]*(?:\.[A-Za-z_][A-Za-z0-9_$]*)?|<=|>=|<>|!=|[=<>]|\S`)
+	equalityTokens = []string{"=", "in", "is"}
+	rangeTokens    = []string{"<", ">", "<=", ">=", "between", "like"}
+)
+
+// SuggestIndexes proposes candidate indices on table for the given
+// representative queries. Columns compared for equality in WHERE and JOIN
+// conditions come first, followed by a single range column or the ORDER BY
+// columns. Candidates already covered by an existing index or the primary key
+// are left out.
+func SuggestIndexes(con DataSource, table string, queries []string) ([]Indices, error) {
+	fields, err := con.GetFields(table)
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[string]string)
+	var primaryKeys []string
+	for _, field := range fields {
+		columns[strings.ToLower(field.Name)] = field.Name
+		if strings.ToUpper(field.Key) == "PRI" {
+			primaryKeys = append(primaryKeys, field.Name)
+		}
+	}
+	var existing []Indices
+	switch con := con.(type) {
+	case *MySQL:
+		existing, err = con.GetTheIndices(table)
+	case *Postgres:
+		existing, err = con.GetTheIndices(table)
+	}
+	if err != nil {
+		return nil, err
+	}
+	covered := [][]string{primaryKeys}
+	for _, index := range existing {
+		covered = append(covered, index.Columns)
+	}
+	var suggestions []Indices
+	for _, query := range queries {
+		candidate := indexCandidate(query, columns)
+		if len(candidate) == 0 || isCoveredIndex(candidate, covered) {
+			continue
+		}
+		covered = append(covered, candidate)
+		suggestions = append(suggestions, Indices{
+			Name:    "idx_" + table + "_" + strings.Join(candidate, "_"),
+			Columns: candidate,
+		})
+	}
+	return suggestions, nil
+}
+
+func indexCandidate(query string, columns map[string]string) []string {
+	tokens := queryTokens.FindAllString(query, -1)
+	var equality, ranges, order []string
+	clause := ""
+	for i, token := range tokens {
+		lower := strings.ToLower(token)
+		switch lower {
+		case "where", "on", "having":
+			clause = "filter"
+			continue
+		case "by":
+			if i > 0 && strings.ToLower(tokens[i-1]) == "order" {
+				clause = "order"
+			}
+			continue
+		case "select", "from", "join", "group", "order", "limit", "offset", "union":
+			clause = ""
+			continue
+		}
+		column, ok := columns[unqualified(lower)]
+		if !ok || clause == "" {
+			continue
+		}
+		if clause == "order" {
+			order = appendUnique(order, column)
+			continue
+		}
+		operator := ""
+		if i+1 < len(tokens) {
+			operator = strings.ToLower(tokens[i+1])
+			if operator == "not" && i+2 < len(tokens) {
+				operator = strings.ToLower(tokens[i+2])
+			}
+		}
+		if (operator == "" || !contains(rangeTokens, operator)) && i > 0 {
+			if previous := strings.ToLower(tokens[i-1]); contains(equalityTokens, previous) || contains(rangeTokens, previous) {
+				operator = previous
+			}
+		}
+		switch {
+		case contains(equalityTokens, operator):
+			equality = appendUnique(equality, column)
+		case contains(rangeTokens, operator):
+			ranges = appendUnique(ranges, column)
+		}
+	}
+	candidate := equality
+	for _, column := range ranges {
+		if !contains(candidate, column) {
+			return append(candidate, column)
+		}
+	}
+	for _, column := range order {
+		candidate = appendUnique(candidate, column)
+	}
+	return candidate
+}
+
+func isCoveredIndex(candidate []string, covered [][]string) bool {
+	for _, columns := range covered {
+		if len(columns) < len(candidate) {
+			continue
+		}
+		prefix := true
+		for i, column := range candidate {
+			if !strings.EqualFold(columns[i], column) {
+				prefix = false
+				break
+			}
+		}
+		if prefix {
+			return true
+		}
+	}
+	return false
+}
+
+func unqualified(name string) string {
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
+
+func appendUnique[T comparable](s []T, v T) []T {
+	if contains(s, v) {
+		return s
+	}
+	return append(s, v)
+}
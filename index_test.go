@@ -0,0 +1,47 @@
+package metadata
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSuggestIndexes(t *testing.T) {
+	source := &fakeSource{
+		dialect: "postgres",
+		fields: map[string][]Field{
+			"orders": {
+				{Name: "id", Key: "PRI"},
+				{Name: "customer_id"},
+				{Name: "status"},
+				{Name: "created_at"},
+			},
+		},
+	}
+	tests := []struct {
+		name    string
+		queries []string
+		want    [][]string
+	}{
+		{name: "equality", queries: []string{"SELECT * FROM orders WHERE customer_id = :id"}, want: [][]string{{"customer_id"}}},
+		{name: "equality then range", queries: []string{"SELECT * FROM orders o WHERE o.status = 'paid' AND o.created_at > :since"}, want: [][]string{{"status", "created_at"}}},
+		{name: "order by", queries: []string{"SELECT * FROM orders WHERE status = :s ORDER BY created_at"}, want: [][]string{{"status", "created_at"}}},
+		{name: "primary key", queries: []string{"SELECT * FROM orders WHERE id = 1"}},
+		{name: "covered by earlier suggestion", queries: []string{"SELECT * FROM orders WHERE status = 1 AND customer_id = 2", "SELECT * FROM orders WHERE status = 1"}, want: [][]string{{"status", "customer_id"}}},
+		{name: "unknown column", queries: []string{"SELECT * FROM orders WHERE total > 10"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			indices, err := SuggestIndexes(source, "orders", tt.queries)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got [][]string
+			for _, index := range indices {
+				got = append(got, index.Columns)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("SuggestIndexes() columns = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
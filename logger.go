@@ -0,0 +1,58 @@
+package metadata
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/oarkflow/squealx"
+	"github.com/oarkflow/squealx/dbresolver"
+)
+
+// Logger is called with each statement a data source runs through Exec,
+// Select or Paginate, its arguments, how long it took and the error it
+// returned.
+type Logger func(sql string, args []any, dur time.Duration, err error)
+
+// loggedClient passes the statements run through Exec, Select and Paginate
+// of the wrapped client to a Logger.
+type loggedClient struct {
+	dbresolver.DBResolver
+	logger Logger
+}
+
+func (c *loggedClient) Exec(query string, args ...any) (sql.Result, error) {
+	start := time.Now()
+	result, err := c.DBResolver.Exec(query, args...)
+	c.logger(query, args, time.Since(start), err)
+	return result, err
+}
+
+func (c *loggedClient) Select(dest any, query string, args ...any) error {
+	start := time.Now()
+	err := c.DBResolver.Select(dest, query, args...)
+	c.logger(query, args, time.Since(start), err)
+	return err
+}
+
+func (c *loggedClient) Paginate(query string, result any, paging squealx.Paging, params ...map[string]any) squealx.PaginatedResponse {
+	start := time.Now()
+	response := c.DBResolver.Paginate(query, result, paging, params...)
+	args := make([]any, len(params))
+	for i, param := range params {
+		args[i] = param
+	}
+	c.logger(query, args, time.Since(start), response.Error)
+	return response
+}
+
+// logClient wraps client to log with logger, unless logging is disabled or
+// there's no logger, replacing the logger of an already wrapped client.
+func logClient(client dbresolver.DBResolver, logger Logger, disabled bool) dbresolver.DBResolver {
+	if logged, ok := client.(*loggedClient); ok {
+		client = logged.DBResolver
+	}
+	if client == nil || logger == nil || disabled {
+		return client
+	}
+	return &loggedClient{DBResolver: client, logger: logger}
+}
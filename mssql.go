@@ -2,8 +2,10 @@ package metadata
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/oarkflow/errors"
 	"github.com/oarkflow/squealx"
 	"github.com/oarkflow/squealx/dbresolver"
 	"github.com/oarkflow/squealx/drivers/mssql"
@@ -16,13 +18,17 @@ type MsSQL struct {
 	id         string
 	client     dbresolver.DBResolver
 	disableLog bool
+	logger     Logger
 	pooling    ConnectionPooling
 	config     Config
+	routes     *clientRoutes
 }
 
 func (p *MsSQL) Connect() (DataSource, error) {
 	if p.client == nil {
-		db1, err := mssql.Open(p.dsn, p.id)
+		db1, err := openWithRetry(p.config, func() (*squealx.DB, error) {
+			return mssql.Open(p.dsn, p.id)
+		})
 		if err != nil {
 			return nil, err
 		}
@@ -30,17 +36,35 @@ func (p *MsSQL) Connect() (DataSource, error) {
 		if err != nil {
 			return nil, err
 		}
-		p.client.SetConnMaxLifetime(time.Duration(p.pooling.MaxLifetime) * time.Second)
+		p.client.SetConnMaxLifetime(p.pooling.maxLifetime())
 		p.client.SetConnMaxIdleTime(time.Duration(p.pooling.MaxIdleTime) * time.Second)
 		p.client.SetMaxOpenConns(p.pooling.MaxOpenCons)
 		p.client.SetMaxIdleConns(p.pooling.MaxIdleCons)
 		p.client.SetDefaultDB(p.id)
+		p.client = logClient(p.client, p.logger, p.disableLog)
 	}
 	return p, nil
 }
 
+// SetLogger sets the Logger called with the statements the data source runs,
+// unless logging is disabled by Config.DisableLogger. A nil logger stops
+// logging.
+func (p *MsSQL) SetLogger(logger Logger) {
+	p.logger = logger
+	p.client = logClient(p.client, logger, p.disableLog)
+}
+
 func (p *MsSQL) GetDBName(database ...string) string {
-	return p.schema
+	db := p.schema
+	if len(database) > 0 {
+		db = database[0]
+	}
+	return db
+}
+
+func (p *MsSQL) GetDatabases() (databases []string, err error) {
+	err = p.client.Select(&databases, "SELECT name FROM sys.databases ORDER BY name")
+	return
 }
 
 func (p *MsSQL) Config() Config {
@@ -53,10 +77,46 @@ func (p *MsSQL) LastInsertedID() (id any, err error) {
 }
 
 func (p *MsSQL) MaxID(table, field string) (id any, err error) {
-	err = p.client.Select(&id, fmt.Sprintf("SELECT MAX(%s) FROM %s;", field, table))
+	if err = validateIdentifier(table); err != nil {
+		return
+	}
+	if err = validateIdentifier(field); err != nil {
+		return
+	}
+	err = p.client.Select(&id, fmt.Sprintf("SELECT MAX(%s) FROM %s;", quoteIdentifier(p.GetType(), field), quoteIdentifier(p.GetType(), table)))
 	return
 }
 
+// ReadOnly returns a copy of the data source that routes every query to the
+// read replicas, to keep heavy introspection off the primary.
+func (p *MsSQL) ReadOnly() (DataSource, error) {
+	client, err := p.routes.route(p.client, true, p.logger, p.disableLog)
+	if err != nil {
+		return nil, err
+	}
+	source := *p
+	source.client = client
+	return &source, nil
+}
+
+// WriteOnly returns a copy of the data source that routes every query to the primaries.
+func (p *MsSQL) WriteOnly() (DataSource, error) {
+	client, err := p.routes.route(p.client, false, p.logger, p.disableLog)
+	if err != nil {
+		return nil, err
+	}
+	source := *p
+	source.client = client
+	return &source, nil
+}
+
+func (p *MsSQL) Ping() error {
+	if p.client == nil {
+		return errors.New("connection not established")
+	}
+	return p.client.Ping()
+}
+
 func (p *MsSQL) Close() error {
 	return p.client.Close()
 }
@@ -79,6 +139,15 @@ func (p *MsSQL) GetTables(database ...string) (tables []Source, err error) {
 	panic("implement me")
 }
 
+// GetTablesMatching returns the tables whose name matches the LIKE pattern.
+func (p *MsSQL) GetTablesMatching(pattern string, database ...string) (tables []Source, err error) {
+	err = p.client.Select(&tables, "SELECT TABLE_NAME AS name, TABLE_TYPE AS table_type FROM INFORMATION_SCHEMA.TABLES WHERE TABLE_TYPE = 'BASE TABLE' AND TABLE_CATALOG = :catalog AND TABLE_NAME LIKE :pattern", map[string]any{
+		"catalog": p.GetDBName(database...),
+		"pattern": pattern,
+	})
+	return
+}
+
 func (p *MsSQL) GetViews(database ...string) (tables []Source, err error) {
 	// TODO implement me
 	panic("implement me")
@@ -89,9 +158,35 @@ func (p *MsSQL) GetFields(table string, database ...string) (fields []Field, err
 	panic("implement me")
 }
 
+// mssqlForeignKeys selects the columns of foreign keys from the sys catalog, as
+// INFORMATION_SCHEMA.KEY_COLUMN_USAGE has no referenced columns in SQL Server.
+const mssqlForeignKeys = `SELECT OBJECT_NAME(fkc.constraint_object_id) AS constraint_name, pt.name AS [table], pc.name AS [name], rt.name AS referenced_table, rc.name AS referenced_column
+FROM sys.foreign_key_columns fkc
+JOIN sys.tables pt ON pt.object_id = fkc.parent_object_id
+JOIN sys.columns pc ON pc.object_id = fkc.parent_object_id AND pc.column_id = fkc.parent_column_id
+JOIN sys.tables rt ON rt.object_id = fkc.referenced_object_id
+JOIN sys.columns rc ON rc.object_id = fkc.referenced_object_id AND rc.column_id = fkc.referenced_column_id`
+
+func (p *MsSQL) InvalidateCache(table string) {}
+
 func (p *MsSQL) GetForeignKeys(table string, database ...string) (fields []ForeignKey, err error) {
-	// TODO implement me
-	panic("implement me")
+	var columns []foreignKeyColumn
+	err = p.client.Select(&columns, mssqlForeignKeys+" WHERE pt.name = :table_name ORDER BY fkc.constraint_object_id, fkc.constraint_column_id;", map[string]any{
+		"table_name": table,
+	})
+	fields = groupForeignKeys(columns)
+	for i := range fields {
+		fields[i].Table = ""
+	}
+	return
+}
+
+func (p *MsSQL) GetReferencingForeignKeys(table string, database ...string) (fields []ForeignKey, err error) {
+	var columns []foreignKeyColumn
+	err = p.client.Select(&columns, mssqlForeignKeys+" WHERE rt.name = :table_name ORDER BY pt.name, fkc.constraint_object_id, fkc.constraint_column_id;", map[string]any{
+		"table_name": table,
+	})
+	return groupForeignKeys(columns), err
 }
 
 func (p *MsSQL) Begin() (squealx.SQLTx, error) {
@@ -103,12 +198,37 @@ func (p *MsSQL) GetIndices(table string, database ...string) (fields []Index, er
 	panic("implement me")
 }
 
+// GetConstraints returns the foreign keys and CHECK constraints of table. The
+// indices aren't read yet.
+func (p *MsSQL) GetConstraints(table string) (*Constraint, error) {
+	foreignKeys, err := p.GetForeignKeys(table)
+	if err != nil {
+		return nil, err
+	}
+	checks, err := p.GetChecks(table)
+	if err != nil {
+		return nil, err
+	}
+	return newConstraint(nil, nil, foreignKeys, checks), nil
+}
+
+// GetChecks returns the CHECK constraints of table.
+func (p *MsSQL) GetChecks(table string) (checks []Check, err error) {
+	err = p.client.Select(&checks, "SELECT cc.name AS name, cc.definition AS expression FROM sys.check_constraints cc JOIN sys.tables t ON t.object_id = cc.parent_object_id WHERE t.name = :table_name ORDER BY cc.name;", map[string]any{
+		"table_name": table,
+	})
+	for i, check := range checks {
+		checks[i].Expression = strings.NewReplacer("[", "", "]", "").Replace(check.Expression)
+	}
+	return
+}
+
 func (p *MsSQL) GetCollection(table string) ([]map[string]any, error) {
 	// TODO implement me
 	panic("implement me")
 }
 
-func (p *MsSQL) Exec(sql string, values ...any) error {
+func (p *MsSQL) Exec(sql string, values ...any) (int64, error) {
 	// TODO implement me
 	panic("implement me")
 }
@@ -118,6 +238,61 @@ func (p *MsSQL) GetRawCollection(query string, params ...map[string]any) ([]map[
 	panic("implement me")
 }
 
+// Explain returns the estimated execution plan of query as XML under the plan
+// key. SHOWPLAN_XML applies to the session, so it runs in a transaction to
+// keep to a single connection.
+func (p *MsSQL) Explain(query string) ([]map[string]any, error) {
+	tx, err := p.client.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("SET SHOWPLAN_XML ON"); err != nil {
+		return nil, err
+	}
+	rows, err := tx.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	var plans []map[string]any
+	for rows.Next() {
+		var plan string
+		if err := rows.Scan(&plan); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		plans = append(plans, map[string]any{"plan": plan})
+	}
+	rows.Close()
+	if _, err := tx.Exec("SET SHOWPLAN_XML OFF"); err != nil {
+		return nil, err
+	}
+	return plans, nil
+}
+
+// QueryInto runs query and scans the rows into dest, a pointer to a slice of
+// structs with db tags.
+func (p *MsSQL) QueryInto(dest any, query string, params ...map[string]any) error {
+	if len(params) > 0 && len(params[0]) > 0 {
+		query, param := expandSliceParams(query, params[0])
+		return p.client.Select(dest, query, param)
+	}
+	return p.client.Select(dest, query)
+}
+
+// EachRow calls fn with each row of query without loading the whole result.
+func (p *MsSQL) EachRow(query string, fn func(row map[string]any) error) error {
+	return eachRow(p.client, query, fn)
+}
+
+// EachRowInTable calls fn with each row of table without loading the whole table.
+func (p *MsSQL) EachRowInTable(table string, fn func(row map[string]any) error) error {
+	if err := validateIdentifier(table); err != nil {
+		return err
+	}
+	return eachRow(p.client, "SELECT * FROM "+quoteIdentifier(p.GetType(), table), fn)
+}
+
 func (p *MsSQL) GetRawPaginatedCollection(query string, paging squealx.Paging, params ...map[string]any) squealx.PaginatedResponse {
 	// TODO implement me
 	panic("implement me")
@@ -138,23 +313,142 @@ func (p *MsSQL) GenerateSQL(table string, newFields []Field, indices ...Indices)
 	panic("implement me")
 }
 
+func (p *MsSQL) RecreateTable(table string, fields []Field, constraints *Constraint) (string, error) {
+	// TODO implement me
+	panic("implement me")
+}
+
 func (p *MsSQL) Migrate(table string, dst DataSource) error {
 	// TODO implement me
 	panic("implement me")
 }
 
 func (p *MsSQL) Store(table string, val any) error {
-	_, err := p.client.Exec(orm.InsertQuery(table, val), val)
+	if err := validateInsert(table, val); err != nil {
+		return err
+	}
+	_, err := p.client.Exec(insertQuery(p.GetType(), table, val), val)
+	return err
+}
+
+// StoreReturning inserts val and returns the returning columns of the inserted
+// row, including values generated by the server.
+func (p *MsSQL) StoreReturning(table string, val any, returning []string) (map[string]any, error) {
+	if err := validateInsert(table, val, returning...); err != nil {
+		return nil, err
+	}
+	columns := []string{"INSERTED.*"}
+	if len(returning) > 0 {
+		columns = make([]string, len(returning))
+		for i, column := range returning {
+			columns[i] = "INSERTED." + quoteIdentifier(p.GetType(), column)
+		}
+	}
+	fields := orm.Fields(val)
+	query := fmt.Sprintf("INSERT INTO %s(%s) OUTPUT %s VALUES (:%s)", quoteIdentifier(p.GetType(), table), strings.Join(quoteIdentifiers(p.GetType(), fields), ", "), strings.Join(columns, ", "), strings.Join(fields, ", :"))
+	var rows []map[string]any
+	if err := p.client.Select(&rows, query, val); err != nil {
+		return nil, err
+	}
+	return firstRow(table, rows)
+}
+
+func (p *MsSQL) Upsert(table string, val any, conflictColumns []string) error {
+	if len(conflictColumns) == 0 {
+		return errors.New("no conflict columns provided")
+	}
+	if err := validateInsert(table, val, conflictColumns...); err != nil {
+		return err
+	}
+	fields := orm.Fields(val)
+	columns := quoteIdentifiers(p.GetType(), fields)
+	var on, updates, sourceFields []string
+	for _, column := range quoteIdentifiers(p.GetType(), conflictColumns) {
+		on = append(on, fmt.Sprintf("target.%s = source.%s", column, column))
+	}
+	for _, column := range quoteIdentifiers(p.GetType(), upsertColumns(val, conflictColumns)) {
+		updates = append(updates, fmt.Sprintf("target.%s = source.%s", column, column))
+	}
+	for _, column := range columns {
+		sourceFields = append(sourceFields, "source."+column)
+	}
+	query := fmt.Sprintf("MERGE INTO %s AS target USING (VALUES (:%s)) AS source (%s) ON %s", quoteIdentifier(p.GetType(), table), strings.Join(fields, ", :"), strings.Join(columns, ", "), strings.Join(on, " AND "))
+	if len(updates) > 0 {
+		query += " WHEN MATCHED THEN UPDATE SET " + strings.Join(updates, ", ")
+	}
+	query += fmt.Sprintf(" WHEN NOT MATCHED THEN INSERT (%s) VALUES (%s);", strings.Join(columns, ", "), strings.Join(sourceFields, ", "))
+	_, err := p.client.Exec(query, val)
+	return err
+}
+
+// Delete deletes the rows of table matching all the column values of where
+// and returns the number of rows deleted.
+func (p *MsSQL) Delete(table string, where map[string]any) (int64, error) {
+	query, params, err := deleteQuery(p.GetType(), table, where)
+	if err != nil {
+		return 0, err
+	}
+	return execAffected(p.client, query, params)
+}
+
+// Update sets the column values of set on the rows of table matching all the
+// column values of where and returns the number of rows updated.
+func (p *MsSQL) Update(table string, set, where map[string]any, opts ...UpdateOptions) (int64, error) {
+	query, params, err := updateQuery(p.GetType(), table, set, where, opts...)
+	if err != nil {
+		return 0, err
+	}
+	return execAffected(p.client, query, params)
+}
+
+func (p *MsSQL) Truncate(table string, opts ...TruncateOptions) error {
+	if err := validateIdentifier(table); err != nil {
+		return err
+	}
+	_, err := p.client.Exec("TRUNCATE TABLE " + quoteIdentifier(p.GetType(), table))
+	return err
+}
+
+func (p *MsSQL) RenameTable(oldName, newName string) error {
+	if err := validateIdentifier(oldName); err != nil {
+		return err
+	}
+	if err := validateIdentifier(newName); err != nil {
+		return err
+	}
+	_, err := p.client.Exec("EXEC sp_rename @p1, @p2", oldName, newName)
 	return err
 }
 
 func (p *MsSQL) StoreInBatches(table string, val any, size int) error {
-	return processBatchInsert(p.client, table, val, size)
+	if err := validateInsert(table, val); err != nil {
+		return err
+	}
+	return processBatchInsert(p.client, p.GetType(), table, val, size)
+}
+
+// DSN returns the DSN the data source connects with, with the password
+// masked when redact is set.
+func (p *MsSQL) DSN(redact ...bool) string {
+	if len(redact) > 0 && redact[0] {
+		return redactDSN(p.dsn)
+	}
+	return p.dsn
 }
 
 func (p *MsSQL) GetType() string {
-	// TODO implement me
-	panic("implement me")
+	return "mssql"
+}
+
+// Capabilities of MsSQL. IF NOT EXISTS isn't assumed as it requires SQL Server 2016.
+func (p *MsSQL) Capabilities() Capability {
+	return Capability{
+		TransactionalDDL: true,
+		DropColumn:       true,
+		AlterColumnType:  true,
+		CheckConstraints: true,
+		Upsert:           true,
+	}
 }
 
 func NewMsSQL(id, dsn, database string, disableLog bool, pooling ConnectionPooling) *MsSQL {
@@ -164,5 +458,6 @@ func NewMsSQL(id, dsn, database string, disableLog bool, pooling ConnectionPooli
 		id:         id,
 		disableLog: disableLog,
 		pooling:    pooling,
+		routes:     newClientRoutes(),
 	}
 }
@@ -0,0 +1,13 @@
+package metadata
+
+import "testing"
+
+func TestMsSQLGetDBName(t *testing.T) {
+	p := NewMsSQL("test", "", "app", true, ConnectionPooling{})
+	if got := p.GetDBName(); got != "app" {
+		t.Errorf("GetDBName() = %q, want %q", got, "app")
+	}
+	if got := p.GetDBName("reports"); got != "reports" {
+		t.Errorf("GetDBName(%q) = %q, want %q", "reports", got, "reports")
+	}
+}
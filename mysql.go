@@ -3,13 +3,16 @@ package metadata
 import (
 	"encoding/json"
 	"fmt"
+	"regexp"
+	"sort"
 	"strings"
 	"time"
 
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"github.com/oarkflow/errors"
 	"github.com/oarkflow/squealx"
 	"github.com/oarkflow/squealx/dbresolver"
 	"github.com/oarkflow/squealx/drivers/mysql"
-	"github.com/oarkflow/squealx/orm"
 )
 
 type MySQL struct {
@@ -18,8 +21,14 @@ type MySQL struct {
 	id         string
 	client     dbresolver.DBResolver
 	disableLog bool
+	logger     Logger
 	pooling    ConnectionPooling
 	config     Config
+	routes     *clientRoutes
+	cache      *schemaCache
+	// mariadb is set for a MariaDB server, which shares the MySQL queries
+	// but differs in a few column definitions and supports RETURNING.
+	mariadb bool
 }
 
 var mysqlQueries = map[string]string{
@@ -37,6 +46,8 @@ var mysqlDataTypes = map[string]string{
 	"int":       "INTEGER",
 	"integer":   "INTEGER",
 	"bigint":    "BIGINT",
+	"smallint":  "SMALLINT",
+	"mediumint": "MEDIUMINT",
 	"float":     "FLOAT",
 	"double":    "DOUBLE",
 	"decimal":   "DECIMAL",
@@ -51,11 +62,24 @@ var mysqlDataTypes = map[string]string{
 	"timestamp": "TIMESTAMP",
 	"bool":      "TINYINT",
 	"boolean":   "TINYINT",
+	"json":      "JSON",
+}
+
+// mysqlArrayField stores array columns as JSON, as MySQL has no array types.
+func mysqlArrayField(f Field) Field {
+	if f.ArrayOf != "" || f.DataType == "array" {
+		f.DataType = "json"
+		f.ArrayOf = ""
+		f.Length = 0
+	}
+	return f
 }
 
 func (p *MySQL) Connect() (DataSource, error) {
 	if p.client == nil {
-		db1, err := mysql.Open(p.dsn, p.id)
+		db1, err := openWithRetry(p.config, func() (*squealx.DB, error) {
+			return mysql.Open(p.dsn, p.id)
+		})
 		if err != nil {
 			return nil, err
 		}
@@ -63,15 +87,24 @@ func (p *MySQL) Connect() (DataSource, error) {
 		if err != nil {
 			return nil, err
 		}
-		p.client.SetConnMaxLifetime(time.Duration(p.pooling.MaxLifetime) * time.Second)
+		p.client.SetConnMaxLifetime(p.pooling.maxLifetime())
 		p.client.SetConnMaxIdleTime(time.Duration(p.pooling.MaxIdleTime) * time.Second)
 		p.client.SetMaxOpenConns(p.pooling.MaxOpenCons)
 		p.client.SetMaxIdleConns(p.pooling.MaxIdleCons)
 		p.client.SetDefaultDB(p.id)
+		p.client = logClient(p.client, p.logger, p.disableLog)
 	}
 	return p, nil
 }
 
+// SetLogger sets the Logger called with the statements the data source runs,
+// unless logging is disabled by Config.DisableLogger. A nil logger stops
+// logging.
+func (p *MySQL) SetLogger(logger Logger) {
+	p.logger = logger
+	p.client = logClient(p.client, logger, p.disableLog)
+}
+
 func (p *MySQL) GetSources(database ...string) (tables []Source, err error) {
 	db := p.schema
 	if len(database) > 0 {
@@ -105,6 +138,19 @@ func (p *MySQL) GetTables(database ...string) (tables []Source, err error) {
 	return
 }
 
+// GetTablesMatching returns the tables whose name matches the LIKE pattern.
+func (p *MySQL) GetTablesMatching(pattern string, database ...string) (tables []Source, err error) {
+	db := p.schema
+	if len(database) > 0 {
+		db = database[0]
+	}
+	err = p.client.Select(&tables, "SELECT table_name as name, table_type FROM information_schema.tables WHERE table_schema = :schema AND table_type='BASE TABLE' AND table_name LIKE :pattern", map[string]any{
+		"schema":  db,
+		"pattern": pattern,
+	})
+	return
+}
+
 func (p *MySQL) GetViews(database ...string) (tables []Source, err error) {
 	db := p.schema
 	if len(database) > 0 {
@@ -128,22 +174,170 @@ func (p *MySQL) GetDBName(database ...string) string {
 	return db
 }
 
+func (p *MySQL) GetDatabases() (databases []string, err error) {
+	err = p.client.Select(&databases, "SELECT schema_name FROM information_schema.schemata ORDER BY schema_name")
+	return
+}
+
 func (p *MySQL) Store(table string, val any) error {
-	_, err := p.client.Exec(orm.InsertQuery(table, val), val)
+	if err := validateInsert(table, val); err != nil {
+		return err
+	}
+	_, err := p.client.Exec(insertQuery(p.GetType(), table, val), val)
+	return err
+}
+
+// StoreReturning inserts val and returns the returning columns of the inserted
+// row, including values generated by the server. MySQL has no RETURNING, so
+// the row is selected back by its primary key in the same transaction, using
+// LAST_INSERT_ID() for an auto increment key. MariaDB uses INSERT ... RETURNING.
+func (p *MySQL) StoreReturning(table string, val any, returning []string) (map[string]any, error) {
+	if err := validateInsert(table, val, returning...); err != nil {
+		return nil, err
+	}
+	if p.mariadb {
+		var rows []map[string]any
+		if err := p.client.Select(&rows, insertQuery(p.GetType(), table, val)+" RETURNING "+returningColumns(p.GetType(), returning), val); err != nil {
+			return nil, err
+		}
+		return firstRow(table, rows)
+	}
+	fields, err := p.GetFields(table)
+	if err != nil {
+		return nil, err
+	}
+	var where []string
+	named := false
+	for _, field := range fields {
+		if strings.ToUpper(field.Key) != "PRI" {
+			continue
+		}
+		if strings.Contains(strings.ToLower(field.Extra), "auto_increment") {
+			where = append(where, quoteIdentifier(p.GetType(), field.Name)+" = LAST_INSERT_ID()")
+		} else {
+			where = append(where, fmt.Sprintf("%s = :%s", quoteIdentifier(p.GetType(), field.Name), field.Name))
+			named = true
+		}
+	}
+	if len(where) == 0 {
+		return nil, fmt.Errorf("unable to select the inserted row back: %s has no primary key", table)
+	}
+	tx, err := p.client.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	if _, err = tx.NamedExec(insertQuery(p.GetType(), table, val), val); err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+	var rows []map[string]any
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s", returningColumns(p.GetType(), returning), quoteIdentifier(p.GetType(), table), strings.Join(where, " AND "))
+	if named {
+		err = tx.NamedSelect(&rows, query, val)
+	} else {
+		err = tx.Select(&rows, query)
+	}
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	return firstRow(table, rows)
+}
+
+func (p *MySQL) Upsert(table string, val any, conflictColumns []string) error {
+	if len(conflictColumns) == 0 {
+		return errors.New("no conflict columns provided")
+	}
+	if err := validateInsert(table, val, conflictColumns...); err != nil {
+		return err
+	}
+	var updates []string
+	for _, column := range upsertColumns(val, conflictColumns) {
+		column = quoteIdentifier(p.GetType(), column)
+		updates = append(updates, fmt.Sprintf("%s = VALUES(%s)", column, column))
+	}
+	if len(updates) == 0 {
+		// MySQL has no DO NOTHING, assigning a conflict column to itself keeps the row untouched.
+		column := quoteIdentifier(p.GetType(), conflictColumns[0])
+		updates = append(updates, fmt.Sprintf("%s = %s", column, column))
+	}
+	query := insertQuery(p.GetType(), table, val) + " ON DUPLICATE KEY UPDATE " + strings.Join(updates, ", ")
+	_, err := p.client.Exec(query, val)
+	return err
+}
+
+// Delete deletes the rows of table matching all the column values of where
+// and returns the number of rows deleted.
+func (p *MySQL) Delete(table string, where map[string]any) (int64, error) {
+	query, params, err := deleteQuery(p.GetType(), table, where)
+	if err != nil {
+		return 0, err
+	}
+	return execAffected(p.client, query, params)
+}
+
+// Update sets the column values of set on the rows of table matching all the
+// column values of where and returns the number of rows updated.
+func (p *MySQL) Update(table string, set, where map[string]any, opts ...UpdateOptions) (int64, error) {
+	query, params, err := updateQuery(p.GetType(), table, set, where, opts...)
+	if err != nil {
+		return 0, err
+	}
+	return execAffected(p.client, query, params)
+}
+
+func (p *MySQL) Truncate(table string, opts ...TruncateOptions) error {
+	if err := validateIdentifier(table); err != nil {
+		return err
+	}
+	_, err := p.client.Exec("TRUNCATE TABLE " + quoteIdentifier(p.GetType(), table))
+	return err
+}
+
+func (p *MySQL) RenameTable(oldName, newName string) error {
+	if err := validateIdentifier(oldName); err != nil {
+		return err
+	}
+	if err := validateIdentifier(newName); err != nil {
+		return err
+	}
+	_, err := p.client.Exec(fmt.Sprintf("RENAME TABLE %s TO %s", quoteIdentifier(p.GetType(), oldName), quoteIdentifier(p.GetType(), newName)))
+	p.cache.invalidate(oldName)
+	p.cache.invalidate(newName)
 	return err
 }
 
 func (p *MySQL) StoreInBatches(table string, val any, size int) error {
-	return processBatchInsert(p.client, table, val, size)
+	if err := validateInsert(table, val); err != nil {
+		return err
+	}
+	return processBatchInsert(p.client, p.GetType(), table, val, size)
 }
 
-func (p *MySQL) GetFields(table string, database ...string) (fields []Field, err error) {
-	db := p.schema
-	if len(database) > 0 {
-		db = database[0]
+func (p *MySQL) GetFields(table string, database ...string) ([]Field, error) {
+	db := p.GetDBName(database...)
+	if fields, ok := p.cache.getFields(db, table); ok {
+		return fields, nil
+	}
+	fields, err := p.getFields(table, db)
+	if err == nil {
+		p.cache.setFields(db, table, fields)
 	}
+	return fields, err
+}
+
+// InvalidateCache drops the cached fields and indices of table, of every
+// table when it's empty.
+func (p *MySQL) InvalidateCache(table string) {
+	p.cache.invalidate(table)
+}
+
+func (p *MySQL) getFields(table, db string) (fields []Field, err error) {
 	var fieldMaps []map[string]any
-	err = p.client.Select(&fieldMaps, "SELECT column_name as `name`, column_default as `default`, is_nullable as `is_nullable`, data_type as type, CASE WHEN numeric_precision IS NOT NULL THEN numeric_precision ELSE character_maximum_length END as `length`, numeric_scale as `precision`, column_comment as `comment`, column_key as `key`, extra as extra FROM INFORMATION_SCHEMA.COLUMNS WHERE TABLE_NAME =  :table_name AND TABLE_SCHEMA = :schema;", map[string]any{
+	err = p.client.Select(&fieldMaps, "SELECT column_name as `name`, column_default as `default`, is_nullable as `is_nullable`, data_type as type, CASE WHEN numeric_precision IS NOT NULL THEN numeric_precision ELSE character_maximum_length END as `length`, numeric_scale as `precision`, column_comment as `comment`, column_key as `key`, extra as extra, generation_expression as `generated_expr`, column_type as `column_type`, character_set_name as `charset`, collation_name as `collation` FROM INFORMATION_SCHEMA.COLUMNS WHERE TABLE_NAME =  :table_name AND TABLE_SCHEMA = :schema;", map[string]any{
 		"schema":     db,
 		"table_name": table,
 	})
@@ -155,19 +349,157 @@ func (p *MySQL) GetFields(table string, database ...string) (fields []Field, err
 		return
 	}
 	err = json.Unmarshal(bt, &fields)
+	if err != nil {
+		return
+	}
+	for i, field := range fields {
+		if strings.Contains(strings.ToUpper(field.Extra), "STORED GENERATED") {
+			fields[i].Stored = true
+		}
+		if columnType, ok := fieldMaps[i]["column_type"].(string); ok {
+			fields[i].Unsigned = strings.Contains(strings.ToLower(columnType), "unsigned")
+			fields[i].EnumValues = parseEnumValues(columnType)
+		}
+	}
+	err = p.setColumnFormats(db, table, fields)
 	return
 }
 
+var (
+	columnFormat = regexp.MustCompile("(?m)^\\s*`([^`]+)`.*\\sCOLUMN_FORMAT (FIXED|DYNAMIC)")
+	rowFormat    = regexp.MustCompile(`(?i)row_format=(\w+)`)
+)
+
+// showCreateTable returns the CREATE TABLE statement MySQL reports for table.
+func (p *MySQL) showCreateTable(db, table string) (string, error) {
+	if err := validateIdentifiers(db, table); err != nil {
+		return "", err
+	}
+	var rows []map[string]any
+	if err := p.client.Select(&rows, "SHOW CREATE TABLE "+quoteIdentifier("mysql", db+"."+table)); err != nil {
+		return "", err
+	}
+	if len(rows) == 0 {
+		return "", nil
+	}
+	switch create := rows[0]["Create Table"].(type) {
+	case string:
+		return create, nil
+	case []byte:
+		return string(create), nil
+	}
+	return "", nil
+}
+
+// setColumnFormats populates the COLUMN_FORMAT of the fields, which
+// information_schema doesn't expose.
+func (p *MySQL) setColumnFormats(db, table string, fields []Field) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	create, err := p.showCreateTable(db, table)
+	if err != nil {
+		return err
+	}
+	formats := make(map[string]string)
+	for _, match := range columnFormat.FindAllStringSubmatch(create, -1) {
+		formats[match[1]] = match[2]
+	}
+	for i, field := range fields {
+		fields[i].ColumnFormat = formats[field.Name]
+	}
+	return nil
+}
+
+// GetPartitioning returns the partitioning of table, or nil when it isn't partitioned.
+func (p *MySQL) GetPartitioning(table string, database ...string) (*Partitioning, error) {
+	db := p.schema
+	if len(database) > 0 {
+		db = database[0]
+	}
+	var partitions []struct {
+		Name        string `db:"name"`
+		Method      string `db:"method"`
+		Expression  string `db:"expression"`
+		Description string `db:"description"`
+	}
+	err := p.client.Select(&partitions, "SELECT partition_name as `name`, partition_method as `method`, partition_expression as `expression`, COALESCE(partition_description, '') as `description` FROM information_schema.partitions WHERE table_schema = :schema AND table_name = :table_name AND partition_name IS NOT NULL AND subpartition_name IS NULL ORDER BY partition_ordinal_position;", map[string]any{
+		"schema":     db,
+		"table_name": table,
+	})
+	if err != nil || len(partitions) == 0 {
+		return nil, err
+	}
+	partitioning := &Partitioning{Key: fmt.Sprintf("%s (%s)", partitions[0].Method, partitions[0].Expression)}
+	for _, partition := range partitions {
+		bound := ""
+		switch {
+		case strings.HasPrefix(partition.Method, "RANGE") && partition.Description == "MAXVALUE":
+			bound = "VALUES LESS THAN MAXVALUE"
+		case strings.HasPrefix(partition.Method, "RANGE"):
+			bound = "VALUES LESS THAN (" + partition.Description + ")"
+		case strings.HasPrefix(partition.Method, "LIST"):
+			bound = "VALUES IN (" + partition.Description + ")"
+		}
+		partitioning.Partitions = append(partitioning.Partitions, Partition{Name: partition.Name, Bound: bound})
+	}
+	return partitioning, nil
+}
+
+func mysqlPartitionSQL(table string, partitioning *Partitioning) string {
+	partitions := make([]string, len(partitioning.Partitions))
+	for i, partition := range partitioning.Partitions {
+		partitions[i] = strings.TrimSpace("PARTITION " + partition.Name + " " + partition.Bound)
+	}
+	return fmt.Sprintf("ALTER TABLE %s PARTITION BY %s (%s);", quoteIdentifier("mysql", table), partitioning.Key, strings.Join(partitions, ", "))
+}
+
+// GetRowFormat returns the ROW_FORMAT table was created with, or an empty
+// string when it uses the default of the storage engine.
+func (p *MySQL) GetRowFormat(table string, database ...string) (string, error) {
+	db := p.schema
+	if len(database) > 0 {
+		db = database[0]
+	}
+	var options []string
+	err := p.client.Select(&options, "SELECT create_options FROM information_schema.tables WHERE table_schema = :schema AND table_name = :table_name;", map[string]any{
+		"schema":     db,
+		"table_name": table,
+	})
+	if err != nil || len(options) == 0 {
+		return "", err
+	}
+	if match := rowFormat.FindStringSubmatch(options[0]); match != nil {
+		return strings.ToUpper(match[1]), nil
+	}
+	return "", nil
+}
+
 func (p *MySQL) GetForeignKeys(table string, database ...string) (fields []ForeignKey, err error) {
 	db := p.schema
 	if len(database) > 0 {
 		db = database[0]
 	}
-	err = p.client.Select(&fields, "SELECT distinct cu.column_name as `name`, cu.referenced_table_name as `referenced_table`, cu.referenced_column_name as `referenced_column` FROM information_schema.key_column_usage cu INNER JOIN information_schema.referential_constraints rc ON rc.constraint_schema = cu.table_schema AND rc.table_name = cu.table_name AND rc.constraint_name = cu.constraint_name WHERE cu.table_name=:table_name AND TABLE_SCHEMA=:schema;", map[string]any{
+	var columns []foreignKeyColumn
+	err = p.client.Select(&columns, "SELECT cu.constraint_name as `constraint_name`, cu.column_name as `name`, cu.referenced_table_name as `referenced_table`, cu.referenced_column_name as `referenced_column` FROM information_schema.key_column_usage cu INNER JOIN information_schema.referential_constraints rc ON rc.constraint_schema = cu.table_schema AND rc.table_name = cu.table_name AND rc.constraint_name = cu.constraint_name WHERE cu.table_name=:table_name AND TABLE_SCHEMA=:schema ORDER BY cu.constraint_name, cu.ordinal_position;", map[string]any{
 		"schema":     db,
 		"table_name": table,
 	})
-	return
+	return groupForeignKeys(columns), err
+}
+
+// GetReferencingForeignKeys returns the foreign keys of other tables that reference table.
+func (p *MySQL) GetReferencingForeignKeys(table string, database ...string) (fields []ForeignKey, err error) {
+	db := p.schema
+	if len(database) > 0 {
+		db = database[0]
+	}
+	var columns []foreignKeyColumn
+	err = p.client.Select(&columns, "SELECT cu.constraint_name as `constraint_name`, cu.table_name as `table`, cu.column_name as `name`, cu.referenced_table_name as `referenced_table`, cu.referenced_column_name as `referenced_column` FROM information_schema.key_column_usage cu INNER JOIN information_schema.referential_constraints rc ON rc.constraint_schema = cu.table_schema AND rc.table_name = cu.table_name AND rc.constraint_name = cu.constraint_name WHERE cu.referenced_table_name=:table_name AND cu.referenced_table_schema=:schema ORDER BY cu.table_name, cu.constraint_name, cu.ordinal_position;", map[string]any{
+		"schema":     db,
+		"table_name": table,
+	})
+	return groupForeignKeys(columns), err
 }
 
 func (p *MySQL) GetIndices(table string, database ...string) (fields []Index, err error) {
@@ -175,49 +507,164 @@ func (p *MySQL) GetIndices(table string, database ...string) (fields []Index, er
 	if len(database) > 0 {
 		db = database[0]
 	}
-	err = p.client.Select(&fields, "SELECT DISTINCT s.index_name as name, s.column_name as column_name, s.nullable as `nullable` FROM INFORMATION_SCHEMA.STATISTICS s LEFT OUTER JOIN INFORMATION_SCHEMA.TABLE_CONSTRAINTS t ON t.TABLE_SCHEMA = s.TABLE_SCHEMA AND t.TABLE_NAME = s.TABLE_NAME AND s.INDEX_NAME = t.CONSTRAINT_NAME WHERE s.TABLE_NAME=:table_name AND s.TABLE_SCHEMA = :schema;", map[string]any{
+	err = p.client.Select(&fields, "SELECT DISTINCT s.index_name as name, s.column_name as column_name, s.nullable as `nullable`, COALESCE(t.constraint_type, 'INDEX') as `type` FROM INFORMATION_SCHEMA.STATISTICS s LEFT OUTER JOIN INFORMATION_SCHEMA.TABLE_CONSTRAINTS t ON t.TABLE_SCHEMA = s.TABLE_SCHEMA AND t.TABLE_NAME = s.TABLE_NAME AND s.INDEX_NAME = t.CONSTRAINT_NAME WHERE s.TABLE_NAME=:table_name AND s.TABLE_SCHEMA = :schema;", map[string]any{
 		"schema":     db,
 		"table_name": table,
 	})
 	return
 }
 
-func (p *MySQL) GetTheIndices(table string, database ...string) (fields []Indices, err error) {
+func (p *MySQL) GetTheIndices(table string, database ...string) ([]Indices, error) {
+	db := p.GetDBName(database...)
+	if indices, ok := p.cache.getIndices(db, table); ok {
+		return indices, nil
+	}
+	indices, err := p.getTheIndices(table, db)
+	if err == nil {
+		p.cache.setIndices(db, table, indices)
+	}
+	return indices, err
+}
+
+func (p *MySQL) getTheIndices(table, db string) (fields []Indices, err error) {
+	params := map[string]any{
+		"schema":     db,
+		"table_name": table,
+	}
+	// Functional key parts have no column name but an expression, which
+	// STATISTICS only reports as of MySQL 8.0.13.
+	err = p.client.Select(&fields, fmt.Sprintf(mysqlIndices, `COALESCE(COLUMN_NAME, REPLACE(EXPRESSION, '"', '\\"'))`), params)
+	if err != nil {
+		fields = nil
+		err = p.client.Select(&fields, fmt.Sprintf(mysqlIndices, "COLUMN_NAME"), params)
+	}
+	return
+}
+
+const mysqlIndices = `SELECT INDEX_NAME AS name, NON_UNIQUE = 0 as ` + "`unique`" + `, CONCAT('[', GROUP_CONCAT(CONCAT('"',%[1]s,'"') ORDER BY SEQ_IN_INDEX) ,']') AS columns, CONCAT('[', COALESCE(GROUP_CONCAT(CASE WHEN COLLATION = 'D' THEN CONCAT('"',%[1]s,'"') END ORDER BY SEQ_IN_INDEX), ''), ']') AS descending FROM information_schema.STATISTICS WHERE TABLE_SCHEMA = :schema AND TABLE_NAME = :table_name GROUP BY INDEX_NAME, NON_UNIQUE;`
+
+func (p *MySQL) GetConstraints(table string) (*Constraint, error) {
+	fields, err := p.GetFields(table)
+	if err != nil {
+		return nil, err
+	}
+	indices, err := p.GetTheIndices(table)
+	if err != nil {
+		return nil, err
+	}
+	foreignKeys, err := p.GetForeignKeys(table)
+	if err != nil {
+		return nil, err
+	}
+	checks, err := p.GetChecks(table)
+	if err != nil {
+		return nil, err
+	}
+	return newConstraint(fields, indices, foreignKeys, checks), nil
+}
+
+// GetChecks returns the CHECK constraints of table. MySQL enforces them since
+// 8.0.16; older servers have none.
+func (p *MySQL) GetChecks(table string, database ...string) (checks []Check, err error) {
 	db := p.schema
 	if len(database) > 0 {
 		db = database[0]
 	}
-	err = p.client.Select(&fields, `SELECT INDEX_NAME AS name, NON_UNIQUE as uniq, CONCAT('[', GROUP_CONCAT(CONCAT('"',COLUMN_NAME,'"') ORDER BY SEQ_IN_INDEX) ,']') AS columns FROM information_schema.STATISTICS WHERE TABLE_SCHEMA = :schema AND TABLE_NAME = :table_name GROUP BY INDEX_NAME, NON_UNIQUE;`, map[string]any{
+	err = p.client.Select(&checks, "SELECT cc.constraint_name as `name`, cc.check_clause as `expression` FROM information_schema.check_constraints cc INNER JOIN information_schema.table_constraints tc ON tc.constraint_schema = cc.constraint_schema AND tc.constraint_name = cc.constraint_name WHERE tc.constraint_type = 'CHECK' AND tc.table_schema = :schema AND tc.table_name = :table_name;", map[string]any{
 		"schema":     db,
 		"table_name": table,
 	})
+	if isMissingTable(err) {
+		// information_schema.check_constraints doesn't exist before 8.0.16.
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i, check := range checks {
+		checks[i].Expression = strings.ReplaceAll(check.Expression, "`", "")
+	}
 	return
 }
 
+// isMissingTable reports whether err is MySQL's unknown table error
+// (ER_UNKNOWN_TABLE, ER_NO_SUCH_TABLE).
+func isMissingTable(err error) bool {
+	var mysqlErr *mysqldriver.MySQLError
+	return errors.As(err, &mysqlErr) && (mysqlErr.Number == 1109 || mysqlErr.Number == 1146)
+}
+
 func (p *MySQL) LastInsertedID() (id any, err error) {
 	err = p.client.Select(&id, "SELECT LAST_INSERT_ID();")
 	return
 }
 
 func (p *MySQL) MaxID(table, field string) (id any, err error) {
-	err = p.client.Select(&id, fmt.Sprintf("SELECT MAX(%s) FROM %s;", field, table))
+	if err = validateIdentifier(table); err != nil {
+		return
+	}
+	if err = validateIdentifier(field); err != nil {
+		return
+	}
+	err = p.client.Select(&id, fmt.Sprintf("SELECT MAX(%s) FROM %s;", quoteIdentifier(p.GetType(), field), quoteIdentifier(p.GetType(), table)))
 	return
 }
 
 func (p *MySQL) GetCollection(table string) ([]map[string]any, error) {
+	if err := validateIdentifier(table); err != nil {
+		return nil, err
+	}
 	var rows []map[string]any
-	err := p.client.Select(&rows, "SELECT * FROM "+table)
+	err := p.client.Select(&rows, "SELECT * FROM "+quoteIdentifier(p.GetType(), table))
 	return rows, err
 }
 
+// ReadOnly returns a copy of the data source that routes every query to the
+// read replicas, to keep heavy introspection off the primary.
+func (p *MySQL) ReadOnly() (DataSource, error) {
+	client, err := p.routes.route(p.client, true, p.logger, p.disableLog)
+	if err != nil {
+		return nil, err
+	}
+	source := *p
+	source.client = client
+	return &source, nil
+}
+
+// WriteOnly returns a copy of the data source that routes every query to the primaries.
+func (p *MySQL) WriteOnly() (DataSource, error) {
+	client, err := p.routes.route(p.client, false, p.logger, p.disableLog)
+	if err != nil {
+		return nil, err
+	}
+	source := *p
+	source.client = client
+	return &source, nil
+}
+
+func (p *MySQL) Ping() error {
+	if p.client == nil {
+		return errors.New("connection not established")
+	}
+	return p.client.Ping()
+}
+
 func (p *MySQL) Close() error {
 	return p.client.Close()
 }
 
-func (p *MySQL) Exec(sql string, values ...any) error {
-	sql = strings.ReplaceAll(sql, `"`, "`")
-	_, err := p.client.Exec(sql, values...)
-	return err
+func (p *MySQL) Exec(sql string, values ...any) (int64, error) {
+	result, err := p.client.Exec(mysqlQuotes(sql), values...)
+	p.cache.invalidateOnDDL(sql)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// mysqlQuotes quotes identifiers with backticks.
+func mysqlQuotes(sql string) string {
+	return strings.ReplaceAll(sql, `"`, "`")
 }
 
 func (p *MySQL) Begin() (squealx.SQLTx, error) {
@@ -235,6 +682,7 @@ func (p *MySQL) GetRawCollection(query string, params ...map[string]any) ([]map[
 			}
 		}
 		if len(param) > 0 {
+			query, param = expandSliceParams(query, param)
 			if err := p.client.Select(&rows, query, param); err != nil {
 				return nil, err
 			}
@@ -250,29 +698,85 @@ func (p *MySQL) GetRawCollection(query string, params ...map[string]any) ([]map[
 	return rows, nil
 }
 
+// Explain returns the execution plan of query in JSON format.
+func (p *MySQL) Explain(query string) ([]map[string]any, error) {
+	return p.GetRawCollection("EXPLAIN FORMAT=JSON " + query)
+}
+
+// QueryInto runs query and scans the rows into dest, a pointer to a slice of
+// structs with db tags.
+func (p *MySQL) QueryInto(dest any, query string, params ...map[string]any) error {
+	if len(params) > 0 && len(params[0]) > 0 {
+		query, param := expandSliceParams(query, params[0])
+		return p.client.Select(dest, query, param)
+	}
+	return p.client.Select(dest, query)
+}
+
+// EachRow calls fn with each row of query without loading the whole result.
+func (p *MySQL) EachRow(query string, fn func(row map[string]any) error) error {
+	return eachRow(p.client, query, fn)
+}
+
+// EachRowInTable calls fn with each row of table without loading the whole table.
+func (p *MySQL) EachRowInTable(table string, fn func(row map[string]any) error) error {
+	if err := validateIdentifier(table); err != nil {
+		return err
+	}
+	return eachRow(p.client, "SELECT * FROM "+quoteIdentifier(p.GetType(), table), fn)
+}
+
 func (p *MySQL) GetRawPaginatedCollection(query string, paging squealx.Paging, params ...map[string]any) squealx.PaginatedResponse {
 	var rows []map[string]any
 	return p.client.Paginate(query, &rows, paging, params...)
 }
 
 func (p *MySQL) GetPaginated(table string, paging squealx.Paging) squealx.PaginatedResponse {
+	if err := validateIdentifier(table); err != nil {
+		return squealx.PaginatedResponse{Error: err}
+	}
 	var rows []map[string]any
-	return p.client.Paginate("SELECT * FROM "+table, &rows, paging)
+	return p.client.Paginate("SELECT * FROM "+quoteIdentifier(p.GetType(), table), &rows, paging)
 }
 
 func (p *MySQL) GetSingle(table string) (map[string]any, error) {
+	if err := validateIdentifier(table); err != nil {
+		return nil, err
+	}
 	var row map[string]any
-	if err := p.client.Select(&row, fmt.Sprintf("SELECT * FROM %s LIMIT 1", table)); err != nil {
+	if err := p.client.Select(&row, fmt.Sprintf("SELECT * FROM %s LIMIT 1", quoteIdentifier(p.GetType(), table))); err != nil {
 		return nil, err
 	}
 	return row, nil
 }
 
+// DSN returns the DSN the data source connects with, with the password
+// masked when redact is set.
+func (p *MySQL) DSN(redact ...bool) string {
+	if len(redact) > 0 && redact[0] {
+		return redactDSN(p.dsn)
+	}
+	return p.dsn
+}
+
 func (p *MySQL) GetType() string {
 	return "mysql"
 }
 
+// Capabilities of MySQL. DDL statements cause an implicit commit, so they
+// can't be applied atomically.
+func (p *MySQL) Capabilities() Capability {
+	return Capability{
+		DropColumn:       true,
+		AlterColumnType:  true,
+		CheckConstraints: true,
+		IfNotExists:      true,
+		Upsert:           true,
+	}
+}
+
 func getMySQLFieldAlterDataType(table string, f Field) string {
+	f = mysqlArrayField(f)
 	dataTypes := mysqlDataTypes
 	defaultVal := ""
 	if f.Default != nil {
@@ -307,6 +811,9 @@ func getMySQLFieldAlterDataType(table string, f Field) string {
 		}
 	}
 	f.Comment = "COMMENT '" + f.Comment + "'"
+	if f.ColumnFormat != "" {
+		f.Comment += " COLUMN_FORMAT " + f.ColumnFormat
+	}
 	nullable := "NULL"
 	if strings.ToUpper(f.IsNullable) == "NO" {
 		nullable = "NOT NULL"
@@ -315,6 +822,19 @@ func getMySQLFieldAlterDataType(table string, f Field) string {
 		nullable = "NULL"
 		defaultVal = "DEFAULT NULL"
 	}
+	if f.GeneratedExpr != "" {
+		nullable = mysqlGeneratedClause(f) + " " + nullable
+		defaultVal = ""
+	}
+	if f.Unsigned {
+		nullable = "UNSIGNED " + nullable
+	}
+	if charset := mysqlCharsetClause(f); charset != "" {
+		nullable = charset + " " + nullable
+	}
+	if onUpdate := mysqlOnUpdateClause(f); onUpdate != "" {
+		defaultVal = strings.TrimSpace(defaultVal + " " + onUpdate)
+	}
 	switch f.DataType {
 	case "float", "double", "decimal", "numeric":
 		if f.Length == 0 {
@@ -324,33 +844,120 @@ func getMySQLFieldAlterDataType(table string, f Field) string {
 			f.Precision = 2
 		}
 		if f.OldName != "" {
-			return fmt.Sprintf("ALTER TABLE %s CHANGE %s %s %s(%d,%d) %s %s %s;", table, f.OldName, f.Name, dataTypes[f.DataType], f.Length, f.Precision, nullable, defaultVal, f.Comment)
+			return fmt.Sprintf("ALTER TABLE %s CHANGE %s %s %s(%d,%d) %s %s %s;", quoteIdentifier("mysql", table), quoteIdentifier("mysql", f.OldName), quoteIdentifier("mysql", f.Name), dataTypes[f.DataType], f.Length, f.Precision, nullable, defaultVal, f.Comment)
 		}
-		return fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s %s(%d,%d) %s %s %s;", table, f.Name, dataTypes[f.DataType], f.Length, f.Precision, nullable, defaultVal, f.Comment)
+		return fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s %s(%d,%d) %s %s %s;", quoteIdentifier("mysql", table), quoteIdentifier("mysql", f.Name), dataTypes[f.DataType], f.Length, f.Precision, nullable, defaultVal, f.Comment)
 	case "int", "integer":
 		if f.Length == 0 {
 			f.Length = 11
 		}
 		if f.OldName != "" {
-			return fmt.Sprintf("ALTER TABLE %s CHANGE %s %s %s(%d) %s %s %s;", table, f.OldName, f.Name, dataTypes[f.DataType], f.Length, nullable, defaultVal, f.Comment)
+			return fmt.Sprintf("ALTER TABLE %s CHANGE %s %s %s(%d) %s %s %s;", quoteIdentifier("mysql", table), quoteIdentifier("mysql", f.OldName), quoteIdentifier("mysql", f.Name), dataTypes[f.DataType], f.Length, nullable, defaultVal, f.Comment)
 		}
-		return fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s %s(%d) %s %s %s;", table, f.Name, dataTypes[f.DataType], f.Length, nullable, defaultVal, f.Comment)
+		return fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s %s(%d) %s %s %s;", quoteIdentifier("mysql", table), quoteIdentifier("mysql", f.Name), dataTypes[f.DataType], f.Length, nullable, defaultVal, f.Comment)
 	case "string", "varchar", "text", "character varying", "char":
 		if f.Length == 0 {
 			f.Length = 255
 		}
 		if f.OldName != "" {
-			return fmt.Sprintf("ALTER TABLE %s CHANGE %s %s %s(%d) %s %s %s;", table, f.OldName, f.Name, dataTypes[f.DataType], f.Length, nullable, defaultVal, f.Comment)
+			return fmt.Sprintf("ALTER TABLE %s CHANGE %s %s %s(%d) %s %s %s;", quoteIdentifier("mysql", table), quoteIdentifier("mysql", f.OldName), quoteIdentifier("mysql", f.Name), dataTypes[f.DataType], f.Length, nullable, defaultVal, f.Comment)
 		}
-		return fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s %s(%d) %s %s %s;", table, f.Name, dataTypes[f.DataType], f.Length, nullable, defaultVal, f.Comment)
+		return fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s %s(%d) %s %s %s;", quoteIdentifier("mysql", table), quoteIdentifier("mysql", f.Name), dataTypes[f.DataType], f.Length, nullable, defaultVal, f.Comment)
 	default:
 		if f.OldName != "" {
-			return fmt.Sprintf("ALTER TABLE %s CHANGE %s %s %s %s %s %s;", table, f.OldName, f.Name, dataTypes[f.DataType], nullable, defaultVal, f.Comment)
+			return fmt.Sprintf("ALTER TABLE %s CHANGE %s %s %s %s %s %s;", quoteIdentifier("mysql", table), quoteIdentifier("mysql", f.OldName), quoteIdentifier("mysql", f.Name), dataTypes[f.DataType], nullable, defaultVal, f.Comment)
 		}
-		return fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s %s %s %s %s;", table, f.Name, dataTypes[f.DataType], nullable, defaultVal, f.Comment)
+		return fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s %s %s %s %s;", quoteIdentifier("mysql", table), quoteIdentifier("mysql", f.Name), dataTypes[f.DataType], nullable, defaultVal, f.Comment)
 	}
 }
 
+// fieldsEqual compares the columns with mysqlFieldsEqual. MariaDB reports a
+// JSON column as LONGTEXT, which matches a json field.
+func (p *MySQL) fieldsEqual(existing, f Field) bool {
+	if p.mariadb && f.DataType == "json" && strings.EqualFold(existing.DataType, "longtext") {
+		existing.DataType = f.DataType
+	}
+	return mysqlFieldsEqual(existing, f)
+}
+
+// mysqlFieldsEqual reports whether the existing column already matches f.
+// Defaults don't apply to generated columns, their expression is compared instead.
+func mysqlFieldsEqual(existing, f Field) bool {
+	f = mysqlArrayField(f)
+	if mysqlDataTypes[existing.DataType] != mysqlDataTypes[f.DataType] ||
+		existing.Length != f.Length ||
+		existing.Unsigned != f.Unsigned ||
+		mysqlOnUpdateClause(existing) != mysqlOnUpdateClause(f) ||
+		existing.Comment != f.Comment ||
+		(f.Charset != "" && !strings.EqualFold(existing.Charset, f.Charset)) ||
+		(f.Collation != "" && !strings.EqualFold(existing.Collation, f.Collation)) ||
+		(f.ColumnFormat != "" && !strings.EqualFold(existing.ColumnFormat, f.ColumnFormat)) {
+		return false
+	}
+	if existing.GeneratedExpr != "" || f.GeneratedExpr != "" {
+		return normalizeExpression(existing.GeneratedExpr) == normalizeExpression(f.GeneratedExpr) &&
+			existing.Stored == f.Stored
+	}
+	return defaultsEqual(existing.Default, f.Default)
+}
+
+var onUpdateTimestamp = regexp.MustCompile(`(?i)on update (current_timestamp(\(\d*\))?)`)
+
+// mysqlOnUpdateClause returns the ON UPDATE CURRENT_TIMESTAMP clause of a
+// timestamp or datetime column recorded in Extra.
+func mysqlOnUpdateClause(f Field) string {
+	switch strings.ToLower(f.DataType) {
+	case "timestamp", "datetime":
+		if match := onUpdateTimestamp.FindStringSubmatch(f.Extra); match != nil {
+			return "ON UPDATE " + strings.ToUpper(match[1])
+		}
+	}
+	return ""
+}
+
+func mysqlIndexSQL(table string, index Indices) string {
+	query := mysqlQueries["create_index"]
+	if index.Unique {
+		query = mysqlQueries["create_unique_index"]
+	}
+	// MySQL takes an expression key part in its own parentheses.
+	return fmt.Sprintf(query, indexName(table, index), quoteIdentifier("mysql", table), strings.Join(indexKeyParts(index, true), ", "))
+}
+
+// mysqlCharsetClause returns the CHARACTER SET and COLLATE clause of a string column.
+func mysqlCharsetClause(f Field) string {
+	switch strings.ToLower(f.DataType) {
+	case "string", "varchar", "character varying", "char", "text", "tinytext", "mediumtext", "longtext", "enum":
+	default:
+		return ""
+	}
+	var clause []string
+	if f.Charset != "" {
+		clause = append(clause, "CHARACTER SET "+f.Charset)
+	}
+	if f.Collation != "" {
+		clause = append(clause, "COLLATE "+f.Collation)
+	}
+	return strings.Join(clause, " ")
+}
+
+func mysqlColumnPosition(f Field) string {
+	switch {
+	case f.First:
+		return " FIRST"
+	case f.After != "":
+		return " AFTER " + quoteIdentifier("mysql", f.After)
+	}
+	return ""
+}
+
+func mysqlGeneratedClause(f Field) string {
+	if f.Stored {
+		return "GENERATED ALWAYS AS (" + f.GeneratedExpr + ") STORED"
+	}
+	return "GENERATED ALWAYS AS (" + f.GeneratedExpr + ") VIRTUAL"
+}
+
 func (p *MySQL) alterFieldSQL(table string, f, existingField Field) string {
 	newSQL := getMySQLFieldAlterDataType(table, f)
 	existingSQL := getMySQLFieldAlterDataType(table, existingField)
@@ -360,13 +967,10 @@ func (p *MySQL) alterFieldSQL(table string, f, existingField Field) string {
 	return ""
 }
 
-func (p *MySQL) createSQL(table string, newFields []Field, indices ...Indices) (string, error) {
+func (p *MySQL) createSQL(table string, newFields []Field, constraints *Constraint, indices ...Indices) (string, error) {
 	var sql string
-	var query, indexQuery, primaryKeys []string
+	var query, indexQuery []string
 	for _, newField := range newFields {
-		if strings.ToUpper(newField.Key) == "PRI" {
-			primaryKeys = append(primaryKeys, newField.Name)
-		}
 		query = append(query, p.FieldAsString(newField, "column"))
 	}
 	if len(indices) > 0 {
@@ -376,27 +980,18 @@ func (p *MySQL) createSQL(table string, newFields []Field, indices ...Indices) (
 		}
 		fmt.Println(existingIndices)
 		for _, index := range indices {
-			if index.Name == "" {
-				index.Name = "idx_" + table + "_" + strings.Join(index.Columns, "_")
-			}
-			switch index.Unique {
-			case true:
-				query := fmt.Sprintf(mysqlQueries["create_unique_index"], index.Name, table,
-					strings.Join(index.Columns, ", "))
-				indexQuery = append(indexQuery, query)
-			case false:
-				query := fmt.Sprintf(mysqlQueries["create_index"], index.Name, table,
-					strings.Join(index.Columns, ", "))
-				indexQuery = append(indexQuery, query)
-			}
+			indexQuery = append(indexQuery, mysqlIndexSQL(table, index))
 		}
 	}
-	if len(primaryKeys) > 0 {
+	if primaryKeys := primaryKeyColumns(newFields, constraints); len(primaryKeys) > 0 {
+		for i, primaryKey := range primaryKeys {
+			primaryKeys[i] = quoteIdentifier(p.GetType(), primaryKey)
+		}
 		query = append(query, " PRIMARY KEY ("+strings.Join(primaryKeys, ", ")+")")
 	}
 	if len(query) > 0 {
 		fieldsToUpdate := strings.Join(query, ", ")
-		sql = fmt.Sprintf(mysqlQueries["create_table"], table) + " (" + fieldsToUpdate + ");"
+		sql = fmt.Sprintf(mysqlQueries["create_table"], quoteIdentifier(p.GetType(), table)) + " (" + fieldsToUpdate + ");"
 	}
 	if len(indexQuery) > 0 {
 		sql += strings.Join(indexQuery, "")
@@ -406,58 +1001,59 @@ func (p *MySQL) createSQL(table string, newFields []Field, indices ...Indices) (
 
 func (p *MySQL) alterSQL(table string, newFields []Field, indices ...Indices) (string, error) {
 	var sql []string
-	alterTable := "ALTER TABLE " + table
+	alterTable := "ALTER TABLE " + quoteIdentifier(p.GetType(), table)
 	existingFields, err := p.GetFields(table)
 	if err != nil {
 		return "", err
 	}
+	renames, err := columnRenames(newFields, existingFields)
+	if err != nil {
+		return "", err
+	}
+	for _, rename := range renames {
+		sql = append(sql, fmt.Sprintf("%s RENAME COLUMN %s TO %s;", alterTable, quoteIdentifier(p.GetType(), rename.From), quoteIdentifier(p.GetType(), rename.To)))
+	}
+	existingFields = renameColumns(existingFields, renames)
 	for _, newField := range newFields {
+		if newField.After != "" {
+			if err := validateIdentifier(newField.After); err != nil {
+				return "", err
+			}
+		}
 		if newField.IsNullable == "" {
 			newField.IsNullable = "YES"
 		}
+		// The renames already ran, so the field is compared with the column
+		// under its new name, which also covers a rename that ran before.
+		newField.OldName = ""
 		fieldExists := false
-		if newField.OldName == "" {
-			for _, existingField := range existingFields {
-				if existingField.Name == newField.Name {
-					fieldExists = true
-					if mysqlDataTypes[existingField.DataType] != mysqlDataTypes[newField.DataType] ||
-						existingField.Length != newField.Length ||
-						existingField.Default != newField.Default ||
-						existingField.Comment != newField.Comment {
-						qry := p.alterFieldSQL(table, newField, existingField)
-						if qry != "" {
-							sql = append(sql, qry)
-						}
-					}
-					if existingField.IsNullable != newField.IsNullable {
-						sql = append(sql, fmt.Sprintf("%s MODIFY %s;", alterTable, p.FieldAsString(existingField, "column")))
+		for _, existingField := range existingFields {
+			if existingField.Name == newField.Name {
+				fieldExists = true
+				if !p.fieldsEqual(existingField, newField) {
+					qry := p.alterFieldSQL(table, newField, existingField)
+					if qry != "" {
+						sql = append(sql, qry)
 					}
 				}
+				if existingField.IsNullable != newField.IsNullable && newField.GeneratedExpr == "" {
+					sql = append(sql, fmt.Sprintf("%s MODIFY %s;", alterTable, p.FieldAsString(existingField, "column")))
+				}
 			}
 		}
 
 		if !fieldExists {
-			qry := alterTable + " " + p.FieldAsString(newField, "add_column") + ";"
+			qry := alterTable + " " + p.FieldAsString(newField, "add_column") + mysqlColumnPosition(newField) + ";"
 			if qry != "" {
 				sql = append(sql, qry)
 			}
 		}
 	}
-	for _, newField := range newFields {
-		if newField.IsNullable == "" {
-			newField.IsNullable = "YES"
-		}
-		if newField.OldName != "" {
-			for _, existingField := range existingFields {
-				if existingField.Name == newField.Name {
-					qry := p.alterFieldSQL(table, newField, existingField)
-					if qry != "" {
-						sql = append(sql, qry)
-					}
-				}
-			}
-		}
+	indexSQL, err := p.alterIndicesSQL(table, indices)
+	if err != nil {
+		return "", err
 	}
+	sql = append(sql, indexSQL...)
 
 	if len(sql) > 0 {
 		return strings.Join(sql, ""), nil
@@ -465,6 +1061,73 @@ func (p *MySQL) alterSQL(table string, newFields []Field, indices ...Indices) (s
 	return "", nil
 }
 
+// alterIndicesSQL creates the indices of table that are missing and recreates
+// those whose columns changed. Indices that aren't given are dropped only with
+// Config.DropIndices, except the primary key and the indices named after a
+// foreign key, which MySQL needs to keep for the key.
+func (p *MySQL) alterIndicesSQL(table string, indices []Indices) ([]string, error) {
+	existingIndices, err := p.GetTheIndices(table)
+	if err != nil {
+		return nil, err
+	}
+	existing := make(map[string]Indices)
+	for _, index := range existingIndices {
+		if index.Name != "PRIMARY" {
+			existing[index.Name] = index
+		}
+	}
+	var sql []string
+	for _, index := range indices {
+		index.Name = indexName(table, index)
+		if existingIndex, ok := existing[index.Name]; ok {
+			delete(existing, index.Name)
+			if indexColumnsEqual(existingIndex.Columns, index.Columns) && existingIndex.Unique == index.Unique && sameIndexColumns(existingIndex.Descending, index.Descending) {
+				continue
+			}
+			sql = append(sql, fmt.Sprintf("DROP INDEX %s ON %s;", quoteIdentifier(p.GetType(), index.Name), quoteIdentifier(p.GetType(), table)))
+		}
+		sql = append(sql, mysqlIndexSQL(table, index))
+	}
+	if !p.config.DropIndices || len(existing) == 0 {
+		return sql, nil
+	}
+	foreignKeys, err := p.GetForeignKeys(table)
+	if err != nil {
+		return nil, err
+	}
+	for _, fk := range foreignKeys {
+		delete(existing, fk.Name)
+	}
+	names := make([]string, 0, len(existing))
+	for name := range existing {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		sql = append(sql, fmt.Sprintf("DROP INDEX %s ON %s;", quoteIdentifier(p.GetType(), name), quoteIdentifier(p.GetType(), table)))
+	}
+	return sql, nil
+}
+
+// RecreateTable returns the statements rebuilding table with fields, for changes
+// ALTER TABLE can't express. The rows of the kept columns are copied over.
+func (p *MySQL) RecreateTable(table string, fields []Field, constraints *Constraint) (string, error) {
+	existing, err := p.GetFields(table)
+	if err != nil {
+		return "", err
+	}
+	temp := table + "_recreate"
+	var indices []Indices
+	if constraints != nil {
+		indices = constraints.Indices
+	}
+	create, err := p.createSQL(temp, fields, constraints, indices...)
+	if err != nil {
+		return "", err
+	}
+	return recreateTableSQL(p.GetType(), table, temp, existing, fields, constraints, create, fmt.Sprintf("RENAME TABLE %s TO %s;", quoteIdentifier(p.GetType(), temp), quoteIdentifier(p.GetType(), table))), nil
+}
+
 func (p *MySQL) GenerateSQL(table string, newFields []Field, indices ...Indices) (string, error) {
 	sources, err := p.GetSources()
 	if err != nil {
@@ -478,7 +1141,7 @@ func (p *MySQL) GenerateSQL(table string, newFields []Field, indices ...Indices)
 		}
 	}
 	if !sourceExists {
-		return p.createSQL(table, newFields, indices...)
+		return p.createSQL(table, newFields, nil, indices...)
 	}
 	return p.alterSQL(table, newFields, indices...)
 }
@@ -497,6 +1160,7 @@ func (p *MySQL) Migrate(table string, dst DataSource) error {
 }
 
 func (p *MySQL) FieldAsString(f Field, action string) string {
+	f = mysqlArrayField(f)
 	sqlPattern := mysqlQueries
 	dataTypes := mysqlDataTypes
 	nullable := "NULL"
@@ -527,6 +1191,9 @@ func (p *MySQL) FieldAsString(f Field, action string) string {
 	if f.Comment != "" {
 		comment = "COMMENT '" + f.Comment + "'"
 	}
+	if f.ColumnFormat != "" {
+		comment = strings.TrimSpace(comment + " COLUMN_FORMAT " + f.ColumnFormat)
+	}
 	if f.Key != "" && strings.ToUpper(f.Key) == "PRI" && action != "column" {
 		primaryKey = "PRIMARY KEY"
 	}
@@ -535,13 +1202,32 @@ func (p *MySQL) FieldAsString(f Field, action string) string {
 			autoIncrement = "AUTO_INCREMENT"
 		}
 	}
+	if f.GeneratedExpr != "" {
+		nullable = mysqlGeneratedClause(f) + " " + nullable
+		defaultVal = ""
+		autoIncrement = ""
+	}
+	if f.Unsigned {
+		nullable = "UNSIGNED " + nullable
+	}
+	if charset := mysqlCharsetClause(f); charset != "" {
+		nullable = charset + " " + nullable
+	}
+	if onUpdate := mysqlOnUpdateClause(f); onUpdate != "" {
+		defaultVal = strings.TrimSpace(defaultVal + " " + onUpdate)
+	}
+	if p.mariadb && f.DataType == "json" {
+		// JSON is an alias of LONGTEXT on MariaDB, validated by a CHECK constraint.
+		changeColumn := sqlPattern[action] + " %s %s %s %s %s CHECK (JSON_VALID(%s))"
+		return strings.TrimSpace(space.ReplaceAllString(fmt.Sprintf(changeColumn, quoteIdentifier(p.GetType(), f.Name), "LONGTEXT", nullable, primaryKey, autoIncrement, defaultVal, comment, quoteIdentifier(p.GetType(), f.Name)), " "))
+	}
 	switch f.DataType {
 	case "string", "varchar", "text", "char":
 		if f.Length == 0 {
 			f.Length = 255
 		}
 		changeColumn := sqlPattern[action] + "(%d) %s %s %s %s %s"
-		return strings.TrimSpace(space.ReplaceAllString(fmt.Sprintf(changeColumn, f.Name, dataTypes[f.DataType], f.Length, nullable, primaryKey, autoIncrement, defaultVal, comment), " "))
+		return strings.TrimSpace(space.ReplaceAllString(fmt.Sprintf(changeColumn, quoteIdentifier(p.GetType(), f.Name), dataTypes[f.DataType], f.Length, nullable, primaryKey, autoIncrement, defaultVal, comment), " "))
 	case "int", "integer", "big_integer", "bigInteger", "tinyint":
 		if f.Length == 0 {
 			f.Length = 11
@@ -550,7 +1236,7 @@ func (p *MySQL) FieldAsString(f Field, action string) string {
 			f.Length = 1
 		}
 		changeColumn := sqlPattern[action] + "(%d) %s %s %s %s %s"
-		return strings.TrimSpace(space.ReplaceAllString(fmt.Sprintf(changeColumn, f.Name, dataTypes[f.DataType], f.Length, nullable, primaryKey, autoIncrement, defaultVal, comment), " "))
+		return strings.TrimSpace(space.ReplaceAllString(fmt.Sprintf(changeColumn, quoteIdentifier(p.GetType(), f.Name), dataTypes[f.DataType], f.Length, nullable, primaryKey, autoIncrement, defaultVal, comment), " "))
 	case "float", "double", "decimal":
 		if f.Length == 0 {
 			f.Length = 11
@@ -559,10 +1245,13 @@ func (p *MySQL) FieldAsString(f Field, action string) string {
 			f.Precision = 2
 		}
 		changeColumn := sqlPattern[action] + "(%d, %d) %s %s %s %s %s"
-		return strings.TrimSpace(space.ReplaceAllString(fmt.Sprintf(changeColumn, f.Name, dataTypes[f.DataType], f.Length, f.Precision, nullable, primaryKey, autoIncrement, defaultVal, comment), " "))
+		return strings.TrimSpace(space.ReplaceAllString(fmt.Sprintf(changeColumn, quoteIdentifier(p.GetType(), f.Name), dataTypes[f.DataType], f.Length, f.Precision, nullable, primaryKey, autoIncrement, defaultVal, comment), " "))
+	case "enum":
+		changeColumn := sqlPattern[action] + "(%s) %s %s %s %s %s"
+		return strings.TrimSpace(space.ReplaceAllString(fmt.Sprintf(changeColumn, quoteIdentifier(p.GetType(), f.Name), "ENUM", quoteEnumValues(f.EnumValues), nullable, primaryKey, autoIncrement, defaultVal, comment), " "))
 	default:
 		changeColumn := sqlPattern[action] + " %s %s %s %s %s"
-		return strings.TrimSpace(space.ReplaceAllString(fmt.Sprintf(changeColumn, f.Name, dataTypes[f.DataType], nullable, primaryKey, autoIncrement, defaultVal, comment), " "))
+		return strings.TrimSpace(space.ReplaceAllString(fmt.Sprintf(changeColumn, quoteIdentifier(p.GetType(), f.Name), dataTypes[f.DataType], nullable, primaryKey, autoIncrement, defaultVal, comment), " "))
 	}
 }
 
@@ -574,5 +1263,6 @@ func NewMySQL(id, dsn, database string, disableLog bool, pooling ConnectionPooli
 		client:     nil,
 		disableLog: disableLog,
 		pooling:    pooling,
+		routes:     newClientRoutes(),
 	}
 }
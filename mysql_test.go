@@ -0,0 +1,143 @@
+package metadata
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+)
+
+func TestIsMissingTable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "no such table", err: &mysqldriver.MySQLError{Number: 1146}, want: true},
+		{name: "unknown table", err: &mysqldriver.MySQLError{Number: 1109}, want: true},
+		{name: "wrapped", err: fmt.Errorf("select: %w", &mysqldriver.MySQLError{Number: 1146}), want: true},
+		{name: "access denied", err: &mysqldriver.MySQLError{Number: 1142}},
+		{name: "connection", err: fmt.Errorf("connection refused")},
+		{name: "nil"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isMissingTable(tt.err); got != tt.want {
+				t.Fatalf("isMissingTable(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMariaDBJSONColumn(t *testing.T) {
+	f := Field{Name: "payload", DataType: "json", IsNullable: "YES"}
+	tests := []struct {
+		name string
+		db   *MySQL
+		sql  string
+	}{
+		{name: "mysql", db: &MySQL{}, sql: "`payload` JSON NULL"},
+		{name: "mariadb", db: &MySQL{mariadb: true}, sql: "`payload` LONGTEXT NULL CHECK (JSON_VALID(`payload`))"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.db.FieldAsString(f, "column"); got != tt.sql {
+				t.Fatalf("FieldAsString() = %q, want %q", got, tt.sql)
+			}
+		})
+	}
+	existing := Field{Name: "payload", DataType: "longtext", IsNullable: "YES"}
+	if !(&MySQL{mariadb: true}).fieldsEqual(existing, f) {
+		t.Error("expected a MariaDB longtext column to match a json field")
+	}
+	if (&MySQL{}).fieldsEqual(existing, f) {
+		t.Error("expected a MySQL longtext column not to match a json field")
+	}
+}
+
+func TestShowCreateTableValidatesIdentifiers(t *testing.T) {
+	for _, table := range []string{"users`; DROP TABLE users; --", "users`"} {
+		if _, err := (&MySQL{}).showCreateTable("app", table); err == nil {
+			t.Errorf("showCreateTable(%q) expected an error", table)
+		}
+	}
+}
+
+// cachedMySQL returns a MySQL data source whose schema cache holds table with
+// fields and no indices, so alterSQL runs without a database.
+func cachedMySQL(table string, fields []Field) *MySQL {
+	p := &MySQL{cache: newSchemaCache()}
+	p.cache.setFields(p.GetDBName(), table, fields)
+	p.cache.setIndices(p.GetDBName(), table, nil)
+	return p
+}
+
+func TestMySQLAlterRenamedColumn(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing []Field
+		fields   []Field
+		sql      string
+	}{
+		{
+			name:     "rename",
+			existing: []Field{{Name: "name", DataType: "varchar", Length: 50, IsNullable: "YES"}},
+			fields:   []Field{{Name: "full_name", OldName: "name", DataType: "varchar", Length: 50, IsNullable: "YES"}},
+			sql:      "ALTER TABLE `users` RENAME COLUMN `name` TO `full_name`;",
+		},
+		{
+			name:     "rerun",
+			existing: []Field{{Name: "full_name", DataType: "varchar", Length: 50, IsNullable: "YES"}},
+			fields:   []Field{{Name: "full_name", OldName: "name", DataType: "varchar", Length: 50, IsNullable: "YES"}},
+		},
+		{
+			name:     "rename and change type",
+			existing: []Field{{Name: "name", DataType: "varchar", Length: 50, IsNullable: "YES"}},
+			fields:   []Field{{Name: "full_name", OldName: "name", DataType: "varchar", Length: 100, IsNullable: "YES"}},
+			sql:      "ALTER TABLE `users` RENAME COLUMN `name` TO `full_name`;ALTER TABLE `users` MODIFY COLUMN `full_name` VARCHAR(100) NULL  COMMENT '';",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql, err := cachedMySQL("users", tt.existing).alterSQL("users", tt.fields)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sql != tt.sql {
+				t.Fatalf("alterSQL() = %q, want %q", sql, tt.sql)
+			}
+		})
+	}
+}
+
+func TestMySQLAddColumnPosition(t *testing.T) {
+	existing := []Field{{Name: "id", DataType: "int", IsNullable: "NO"}, {Name: "order", DataType: "int", IsNullable: "YES"}}
+	tests := []struct {
+		name    string
+		field   Field
+		suffix  string
+		wantErr bool
+	}{
+		{name: "first", field: Field{Name: "code", DataType: "int", First: true}, suffix: " FIRST;"},
+		{name: "after reserved word", field: Field{Name: "code", DataType: "int", After: "order"}, suffix: " AFTER `order`;"},
+		{name: "invalid after", field: Field{Name: "code", DataType: "int", After: "id`; DROP TABLE users; --"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql, err := cachedMySQL("users", existing).alterSQL("users", append(existing, tt.field))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %q", sql)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasSuffix(sql, tt.suffix) {
+				t.Fatalf("alterSQL() = %q, want suffix %q", sql, tt.suffix)
+			}
+		})
+	}
+}
@@ -3,14 +3,15 @@ package metadata
 import (
 	"encoding/json"
 	"fmt"
-	"reflect"
+	"regexp"
+	"sort"
 	"strings"
 	"time"
 
+	"github.com/oarkflow/errors"
 	"github.com/oarkflow/squealx"
 	"github.com/oarkflow/squealx/dbresolver"
 	"github.com/oarkflow/squealx/drivers/postgres"
-	"github.com/oarkflow/squealx/orm"
 )
 
 type Postgres struct {
@@ -19,14 +20,20 @@ type Postgres struct {
 	id         string
 	client     dbresolver.DBResolver
 	disableLog bool
+	logger     Logger
 	pooling    ConnectionPooling
 	config     Config
+	routes     *clientRoutes
+	cache      *schemaCache
+	// cockroach is set for a CockroachDB server, which speaks the Postgres
+	// protocol but rejects some of its ALTER COLUMN forms.
+	cockroach bool
 }
 
 var postgresQueries = map[string]string{
 	"create_table":        "CREATE TABLE IF NOT EXISTS %s",
 	"alter_table":         "ALTER TABLE %s",
-	"column":              `"%s" %s`,
+	"column":              "%s %s",
 	"add_column":          "ADD COLUMN %s %s",        // {{length}} NOT NULL DEFAULT 1
 	"change_column":       "ALTER COLUMN %s TYPE %s", // {{length}} NOT NULL DEFAULT 1
 	"remove_column":       "ALTER COLUMN % TYPE %s",  // {{length}} NOT NULL DEFAULT 1
@@ -35,47 +42,52 @@ var postgresQueries = map[string]string{
 }
 
 var postgresDataTypes = map[string]string{
-	"smallint":                 "SMALLINT",
-	"int2":                     "SMALLINT",
-	"int":                      "INT",
-	"int4":                     "INT",
-	"integer":                  "INT",
-	"bigint":                   "BIGINT",
-	"int8":                     "BIGINT",
-	"float":                    "NUMERIC",
-	"numeric":                  "NUMERIC",
-	"double":                   "NUMERIC",
-	"decimal":                  "NUMERIC",
-	"tinyint":                  "BOOLEAN",
-	"bool":                     "BOOLEAN",
-	"boolean":                  "BOOLEAN",
-	"string":                   "VARCHAR",
-	"varchar":                  "VARCHAR",
-	"character varying":        "VARCHAR",
-	"year":                     "SMALLINT",
-	"char":                     "CHAR",
-	"character":                "CHAR",
-	"text":                     "TEXT",
-	"longText":                 "TEXT",
-	"longtext":                 "TEXT",
-	"LongText":                 "TEXT",
-	"serial":                   "SERIAL",
-	"serial4":                  "SERIAL",
-	"bigserial":                "BIGSERIAL",
-	"serial8":                  "BIGSERIAL",
-	"datetime":                 "TIMESTAMPTZ",
-	"date":                     "DATE",
-	"time":                     "TIME",
-	"timestamp":                "TIMESTAMP",
-	"timestamptz":              "TIMESTAMPTZ",
-	"timestamp with time zone": "TIMESTAMPTZ",
-	"jsonb":                    "JSONB",
-	"json":                     "JSON",
+	"smallint":                    "SMALLINT",
+	"int2":                        "SMALLINT",
+	"mediumint":                   "INT",
+	"int":                         "INT",
+	"int4":                        "INT",
+	"integer":                     "INT",
+	"bigint":                      "BIGINT",
+	"int8":                        "BIGINT",
+	"float":                       "NUMERIC",
+	"numeric":                     "NUMERIC",
+	"double":                      "NUMERIC",
+	"decimal":                     "NUMERIC",
+	"tinyint":                     "BOOLEAN",
+	"bool":                        "BOOLEAN",
+	"boolean":                     "BOOLEAN",
+	"string":                      "VARCHAR",
+	"varchar":                     "VARCHAR",
+	"character varying":           "VARCHAR",
+	"year":                        "SMALLINT",
+	"char":                        "CHAR",
+	"character":                   "CHAR",
+	"text":                        "TEXT",
+	"longText":                    "TEXT",
+	"longtext":                    "TEXT",
+	"LongText":                    "TEXT",
+	"serial":                      "SERIAL",
+	"serial4":                     "SERIAL",
+	"bigserial":                   "BIGSERIAL",
+	"serial8":                     "BIGSERIAL",
+	"datetime":                    "TIMESTAMPTZ",
+	"date":                        "DATE",
+	"time":                        "TIME",
+	"time without time zone":      "TIME",
+	"timestamp":                   "TIMESTAMP",
+	"timestamp without time zone": "TIMESTAMP",
+	"timestamptz":                 "TIMESTAMPTZ",
+	"timestamp with time zone":    "TIMESTAMPTZ",
+	"jsonb":                       "JSONB",
+	"json":                        "JSON",
 }
 
 func (p *Postgres) Connect() (DataSource, error) {
 	if p.client == nil {
-		db1, err := postgres.Open(p.dsn, p.id)
+		db1, err := openWithRetry(p.config, func() (*squealx.DB, error) {
+			return postgres.Open(p.dsn, p.id)
+		})
 		if err != nil {
 			return nil, err
 		}
@@ -83,15 +95,24 @@ func (p *Postgres) Connect() (DataSource, error) {
 		if err != nil {
 			return nil, err
 		}
-		p.client.SetConnMaxLifetime(time.Duration(p.pooling.MaxLifetime) * time.Second)
+		p.client.SetConnMaxLifetime(p.pooling.maxLifetime())
 		p.client.SetConnMaxIdleTime(time.Duration(p.pooling.MaxIdleTime) * time.Second)
 		p.client.SetMaxOpenConns(p.pooling.MaxOpenCons)
 		p.client.SetMaxIdleConns(p.pooling.MaxIdleCons)
 		p.client.SetDefaultDB(p.id)
+		p.client = logClient(p.client, p.logger, p.disableLog)
 	}
 	return p, nil
 }
 
+// SetLogger sets the Logger called with the statements the data source runs,
+// unless logging is disabled by Config.DisableLogger. A nil logger stops
+// logging.
+func (p *Postgres) SetLogger(logger Logger) {
+	p.logger = logger
+	p.client = logClient(p.client, logger, p.disableLog)
+}
+
 func (p *Postgres) GetSources(database ...string) (tables []Source, err error) {
 	db := p.schema
 	if len(database) > 0 {
@@ -116,9 +137,25 @@ func (p *Postgres) GetTables(database ...string) (tables []Source, err error) {
 	if len(database) > 0 {
 		db = database[0]
 	}
-	sq := "SELECT table_name as name, table_type FROM information_schema.tables WHERE table_catalog = :catalog AND table_schema = 'public' AND table_type='BASE TABLE'"
-	err = p.client.Select(&tables, sq, map[string]any{
+	err = p.client.Select(&tables, postgresTables, map[string]any{
+		"catalog": db,
+	})
+	return
+}
+
+// postgresTables selects the tables of the public schema. Partitions are left
+// out, they're part of their partitioned table.
+const postgresTables = "SELECT table_name as name, table_type FROM information_schema.tables WHERE table_catalog = :catalog AND table_schema = 'public' AND table_type='BASE TABLE' AND table_name NOT IN (SELECT c.relname FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace WHERE n.nspname = 'public' AND c.relispartition)"
+
+// GetTablesMatching returns the tables whose name matches the LIKE pattern.
+func (p *Postgres) GetTablesMatching(pattern string, database ...string) (tables []Source, err error) {
+	db := p.schema
+	if len(database) > 0 {
+		db = database[0]
+	}
+	err = p.client.Select(&tables, postgresTables+" AND table_name LIKE :pattern", map[string]any{
 		"catalog": db,
+		"pattern": pattern,
 	})
 	return
 }
@@ -147,18 +184,37 @@ func (p *Postgres) GetDBName(database ...string) string {
 	return db
 }
 
+func (p *Postgres) GetDatabases() (databases []string, err error) {
+	err = p.client.Select(&databases, "SELECT datname FROM pg_database WHERE NOT datistemplate ORDER BY datname")
+	return
+}
+
 func (p *Postgres) Config() Config {
 	return p.config
 }
 
-func (p *Postgres) GetFields(table string, database ...string) (fields []Field, err error) {
-	db := p.schema
-	if len(database) > 0 {
-		db = database[0]
+func (p *Postgres) GetFields(table string, database ...string) ([]Field, error) {
+	db := p.GetDBName(database...)
+	if fields, ok := p.cache.getFields(db, table); ok {
+		return fields, nil
+	}
+	fields, err := p.getFields(table, db)
+	if err == nil {
+		p.cache.setFields(db, table, fields)
 	}
+	return fields, err
+}
+
+// InvalidateCache drops the cached fields and indices of table, of every
+// table when it's empty.
+func (p *Postgres) InvalidateCache(table string) {
+	p.cache.invalidate(table)
+}
+
+func (p *Postgres) getFields(table, db string) (fields []Field, err error) {
 	var fieldMaps []map[string]any
 	err = p.client.Select(&fieldMaps, `
-SELECT c.column_name as "name", column_default as "default", is_nullable as "is_nullable", data_type as "type", CASE WHEN numeric_precision IS NOT NULL THEN numeric_precision ELSE character_maximum_length END as "length", numeric_scale as "precision",a.column_key as "key", b.comment, '' as extra
+SELECT c.column_name as "name", column_default as "default", is_nullable as "is_nullable", CASE WHEN c.data_type = 'USER-DEFINED' THEN c.udt_name ELSE c.data_type END as "type", CASE WHEN numeric_precision IS NOT NULL THEN numeric_precision ELSE character_maximum_length END as "length", numeric_scale as "precision",a.column_key as "key", b.comment, '' as extra, c.generation_expression as "generated_expr", CASE WHEN c.data_type = 'ARRAY' THEN ltrim(c.udt_name, '_') ELSE '' END as "array_of"
 FROM INFORMATION_SCHEMA.COLUMNS c
 LEFT JOIN (
 select kcu.table_name,        'PRI' as column_key,        kcu.ordinal_position as position,        kcu.column_name as column_name
@@ -196,16 +252,191 @@ WHERE table_catalog = :catalog AND table_schema = 'public' AND c.table_name =  :
 		return
 	}
 	err = json.Unmarshal(bt, &fields)
+	if err != nil {
+		return
+	}
+	for i, field := range fields {
+		if field.GeneratedExpr != "" {
+			fields[i].Stored = true
+		}
+		if field.ArrayOf != "" {
+			fields[i].DataType = "array"
+		}
+		if def, ok := field.Default.(string); ok {
+			fields[i].Default = normalizePostgresDefault(def)
+		}
+	}
+	err = p.setColumnStorage(table, fields)
 	return
 }
 
+var postgresStorageTypes = map[string]string{
+	"p": "PLAIN",
+	"e": "EXTERNAL",
+	"x": "EXTENDED",
+	"m": "MAIN",
+}
+
+var postgresCompressionTypes = map[string]string{
+	"p": "pglz",
+	"l": "lz4",
+}
+
+// setColumnStorage populates the storage strategy and compression method of the
+// fields when they differ from the defaults of the column type.
+func (p *Postgres) setColumnStorage(table string, fields []Field) error {
+	var storages []struct {
+		Name    string `db:"name"`
+		Storage string `db:"storage"`
+	}
+	err := p.client.Select(&storages, `SELECT a.attname AS name, CASE WHEN a.attstorage <> t.typstorage THEN a.attstorage::text ELSE '' END AS storage
+FROM pg_attribute a
+JOIN pg_class c ON c.oid = a.attrelid
+JOIN pg_namespace n ON n.oid = c.relnamespace
+JOIN pg_type t ON t.oid = a.atttypid
+WHERE n.nspname = 'public' AND c.relname = :table_name AND a.attnum > 0 AND NOT a.attisdropped;`, map[string]any{
+		"table_name": table,
+	})
+	if err != nil {
+		return err
+	}
+	// attcompression is only available from Postgres 14 onwards.
+	var compressions []struct {
+		Name        string `db:"name"`
+		Compression string `db:"compression"`
+	}
+	_ = p.client.Select(&compressions, `SELECT a.attname AS name, a.attcompression::text AS compression
+FROM pg_attribute a
+JOIN pg_class c ON c.oid = a.attrelid
+JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE n.nspname = 'public' AND c.relname = :table_name AND a.attnum > 0 AND NOT a.attisdropped;`, map[string]any{
+		"table_name": table,
+	})
+	for i := range fields {
+		for _, storage := range storages {
+			if storage.Name == fields[i].Name {
+				fields[i].Storage = postgresStorageTypes[storage.Storage]
+			}
+		}
+		for _, compression := range compressions {
+			if compression.Name == fields[i].Name {
+				fields[i].Compression = postgresCompressionTypes[compression.Compression]
+			}
+		}
+	}
+	return nil
+}
+
+func postgresStorageSQL(table string, f Field) string {
+	var sql string
+	if f.Storage != "" {
+		sql += fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET STORAGE %s;", quoteIdentifier("postgres", table), quoteIdentifier("postgres", f.Name), strings.ToUpper(f.Storage))
+	}
+	if f.Compression != "" {
+		sql += fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET COMPRESSION %s;", quoteIdentifier("postgres", table), quoteIdentifier("postgres", f.Name), strings.ToLower(f.Compression))
+	}
+	return sql
+}
+
 func (p *Postgres) Store(table string, val any) error {
-	_, err := p.client.Exec(orm.InsertQuery(table, val), val)
+	if err := validateInsert(table, val); err != nil {
+		return err
+	}
+	_, err := p.client.Exec(insertQuery(p.GetType(), table, val), val)
+	return err
+}
+
+// StoreReturning inserts val and returns the returning columns of the inserted
+// row, including values generated by the server.
+func (p *Postgres) StoreReturning(table string, val any, returning []string) (map[string]any, error) {
+	if err := validateInsert(table, val, returning...); err != nil {
+		return nil, err
+	}
+	var rows []map[string]any
+	err := p.client.Select(&rows, insertQuery(p.GetType(), table, val)+" RETURNING "+returningColumns(p.GetType(), returning), val)
+	if err != nil {
+		return nil, err
+	}
+	return firstRow(table, rows)
+}
+
+func (p *Postgres) Upsert(table string, val any, conflictColumns []string) error {
+	if len(conflictColumns) == 0 {
+		return errors.New("no conflict columns provided")
+	}
+	if err := validateInsert(table, val, conflictColumns...); err != nil {
+		return err
+	}
+	var updates []string
+	for _, column := range upsertColumns(val, conflictColumns) {
+		column = quoteIdentifier(p.GetType(), column)
+		updates = append(updates, fmt.Sprintf("%s = EXCLUDED.%s", column, column))
+	}
+	query := insertQuery(p.GetType(), table, val) + " ON CONFLICT (" + strings.Join(quoteIdentifiers(p.GetType(), conflictColumns), ", ") + ")"
+	if len(updates) == 0 {
+		query += " DO NOTHING"
+	} else {
+		query += " DO UPDATE SET " + strings.Join(updates, ", ")
+	}
+	_, err := p.client.Exec(query, val)
+	return err
+}
+
+// Delete deletes the rows of table matching all the column values of where
+// and returns the number of rows deleted.
+func (p *Postgres) Delete(table string, where map[string]any) (int64, error) {
+	query, params, err := deleteQuery(p.GetType(), table, where)
+	if err != nil {
+		return 0, err
+	}
+	return execAffected(p.client, query, params)
+}
+
+// Update sets the column values of set on the rows of table matching all the
+// column values of where and returns the number of rows updated.
+func (p *Postgres) Update(table string, set, where map[string]any, opts ...UpdateOptions) (int64, error) {
+	query, params, err := updateQuery(p.GetType(), table, set, where, opts...)
+	if err != nil {
+		return 0, err
+	}
+	return execAffected(p.client, query, params)
+}
+
+func (p *Postgres) Truncate(table string, opts ...TruncateOptions) error {
+	if err := validateIdentifier(table); err != nil {
+		return err
+	}
+	sql := "TRUNCATE TABLE " + quoteIdentifier(p.GetType(), table)
+	if len(opts) > 0 {
+		if opts[0].RestartIdentity {
+			sql += " RESTART IDENTITY"
+		}
+		if opts[0].Cascade {
+			sql += " CASCADE"
+		}
+	}
+	_, err := p.client.Exec(sql)
+	return err
+}
+
+func (p *Postgres) RenameTable(oldName, newName string) error {
+	if err := validateIdentifier(oldName); err != nil {
+		return err
+	}
+	if err := validateIdentifier(newName); err != nil {
+		return err
+	}
+	_, err := p.client.Exec(fmt.Sprintf("ALTER TABLE %s RENAME TO %s", quoteIdentifier(p.GetType(), oldName), quoteIdentifier(p.GetType(), newName)))
+	p.cache.invalidate(oldName)
+	p.cache.invalidate(newName)
 	return err
 }
 
 func (p *Postgres) StoreInBatches(table string, val any, size int) error {
-	return processBatchInsert(p.client, table, val, size)
+	if err := validateInsert(table, val); err != nil {
+		return err
+	}
+	return processBatchInsert(p.client, p.GetType(), table, val, size)
 }
 
 func (p *Postgres) LastInsertedID() (id any, err error) {
@@ -214,7 +445,13 @@ func (p *Postgres) LastInsertedID() (id any, err error) {
 }
 
 func (p *Postgres) MaxID(table, field string) (id any, err error) {
-	err = p.client.Select(&id, fmt.Sprintf("SELECT MAX(%s) FROM %s;", field, table))
+	if err = validateIdentifier(table); err != nil {
+		return
+	}
+	if err = validateIdentifier(field); err != nil {
+		return
+	}
+	err = p.client.Select(&id, fmt.Sprintf("SELECT MAX(%s) FROM %s;", quoteIdentifier(p.GetType(), field), quoteIdentifier(p.GetType(), table)))
 	return
 }
 
@@ -223,11 +460,26 @@ func (p *Postgres) GetForeignKeys(table string, database ...string) (fields []Fo
 	if len(database) > 0 {
 		db = database[0]
 	}
-	err = p.client.Select(&fields, `select kcu.column_name as "name", rel_kcu.table_name as referenced_table, rel_kcu.column_name as referenced_column from information_schema.table_constraints tco join information_schema.key_column_usage kcu           on tco.constraint_schema = kcu.constraint_schema           and tco.constraint_name = kcu.constraint_name join information_schema.referential_constraints rco           on tco.constraint_schema = rco.constraint_schema           and tco.constraint_name = rco.constraint_name join information_schema.key_column_usage rel_kcu           on rco.unique_constraint_schema = rel_kcu.constraint_schema           and rco.unique_constraint_name = rel_kcu.constraint_name           and kcu.ordinal_position = rel_kcu.ordinal_position where tco.constraint_type = 'FOREIGN KEY' and kcu.table_catalog = :catalog AND kcu.table_schema = 'public' AND kcu.table_name = :table_name order by kcu.table_schema,          kcu.table_name,          kcu.ordinal_position;`, map[string]any{
+	var columns []foreignKeyColumn
+	err = p.client.Select(&columns, `select tco.constraint_name as "constraint_name", kcu.column_name as "name", rel_kcu.table_name as referenced_table, rel_kcu.column_name as referenced_column from information_schema.table_constraints tco join information_schema.key_column_usage kcu           on tco.constraint_schema = kcu.constraint_schema           and tco.constraint_name = kcu.constraint_name join information_schema.referential_constraints rco           on tco.constraint_schema = rco.constraint_schema           and tco.constraint_name = rco.constraint_name join information_schema.key_column_usage rel_kcu           on rco.unique_constraint_schema = rel_kcu.constraint_schema           and rco.unique_constraint_name = rel_kcu.constraint_name           and kcu.ordinal_position = rel_kcu.ordinal_position where tco.constraint_type = 'FOREIGN KEY' and kcu.table_catalog = :catalog AND kcu.table_schema = 'public' AND kcu.table_name = :table_name order by kcu.table_schema,          kcu.table_name,          tco.constraint_name,          kcu.ordinal_position;`, map[string]any{
 		"catalog":    db,
 		"table_name": table,
 	})
-	return
+	return groupForeignKeys(columns), err
+}
+
+// GetReferencingForeignKeys returns the foreign keys of other tables that reference table.
+func (p *Postgres) GetReferencingForeignKeys(table string, database ...string) (fields []ForeignKey, err error) {
+	db := p.schema
+	if len(database) > 0 {
+		db = database[0]
+	}
+	var columns []foreignKeyColumn
+	err = p.client.Select(&columns, `select tco.constraint_name as "constraint_name", kcu.table_name as "table", kcu.column_name as "name", rel_kcu.table_name as referenced_table, rel_kcu.column_name as referenced_column from information_schema.table_constraints tco join information_schema.key_column_usage kcu           on tco.constraint_schema = kcu.constraint_schema           and tco.constraint_name = kcu.constraint_name join information_schema.referential_constraints rco           on tco.constraint_schema = rco.constraint_schema           and tco.constraint_name = rco.constraint_name join information_schema.key_column_usage rel_kcu           on rco.unique_constraint_schema = rel_kcu.constraint_schema           and rco.unique_constraint_name = rel_kcu.constraint_name           and kcu.ordinal_position = rel_kcu.ordinal_position where tco.constraint_type = 'FOREIGN KEY' and rel_kcu.table_catalog = :catalog AND rel_kcu.table_schema = 'public' AND rel_kcu.table_name = :table_name order by kcu.table_name,          tco.constraint_name,          kcu.ordinal_position;`, map[string]any{
+		"catalog":    db,
+		"table_name": table,
+	})
+	return groupForeignKeys(columns), err
 }
 
 func (p *Postgres) GetIndices(table string, database ...string) (fields []Index, err error) {
@@ -235,37 +487,131 @@ func (p *Postgres) GetIndices(table string, database ...string) (fields []Index,
 	if len(database) > 0 {
 		db = database[0]
 	}
-	err = p.client.Select(&fields, `select DISTINCT kcu.constraint_name as "name", kcu.column_name as "column_name", enforced as "nullable" from information_schema.table_constraints tco join information_schema.key_column_usage kcu       on kcu.constraint_name = tco.constraint_name      and kcu.constraint_schema = tco.constraint_schema      and kcu.constraint_name = tco.constraint_name      WHERE tco.table_catalog = :catalog AND tco.table_schema = 'public' AND tco.table_name = :table_name;`, map[string]any{
+	err = p.client.Select(&fields, `select DISTINCT kcu.constraint_name as "name", kcu.column_name as "column_name", enforced as "nullable", tco.constraint_type as "type" from information_schema.table_constraints tco join information_schema.key_column_usage kcu       on kcu.constraint_name = tco.constraint_name      and kcu.constraint_schema = tco.constraint_schema      and kcu.constraint_name = tco.constraint_name      WHERE tco.table_catalog = :catalog AND tco.table_schema = 'public' AND tco.table_name = :table_name;`, map[string]any{
 		"catalog":    db,
 		"table_name": table,
 	})
 	return
 }
 
+// GetPartitioning returns the partitioning of table, or nil when it isn't partitioned.
+func (p *Postgres) GetPartitioning(table string) (*Partitioning, error) {
+	var keys []string
+	err := p.client.Select(&keys, `SELECT pg_get_partkeydef(c.oid) FROM pg_partitioned_table pt JOIN pg_class c ON c.oid = pt.partrelid JOIN pg_namespace n ON n.oid = c.relnamespace WHERE n.nspname = 'public' AND c.relname = :table_name;`, map[string]any{
+		"table_name": table,
+	})
+	if err != nil || len(keys) == 0 {
+		return nil, err
+	}
+	partitioning := &Partitioning{Key: keys[0]}
+	err = p.client.Select(&partitioning.Partitions, `SELECT child.relname AS name, pg_get_expr(child.relpartbound, child.oid) AS bound
+FROM pg_inherits i
+JOIN pg_class parent ON parent.oid = i.inhparent
+JOIN pg_class child ON child.oid = i.inhrelid
+JOIN pg_namespace n ON n.oid = parent.relnamespace
+WHERE n.nspname = 'public' AND parent.relname = :table_name
+ORDER BY child.relname;`, map[string]any{
+		"table_name": table,
+	})
+	return partitioning, err
+}
+
+// postgresPartitionSQL partitions the table created by the CREATE TABLE
+// statement of sql and creates its partitions. Partitioning can only be set
+// when creating a table, so sql is returned as is when it alters one.
+func postgresPartitionSQL(sql, src, dest string, partitioning *Partitioning) string {
+	statements := splitStatements(sql)
+	create := fmt.Sprintf(postgresQueries["create_table"], quoteIdentifier("postgres", dest)) + " "
+	for i, statement := range statements {
+		if !strings.HasPrefix(statement, create) {
+			continue
+		}
+		statements[i] = statement + " PARTITION BY " + partitioning.Key
+		for _, partition := range partitioning.Partitions {
+			name := cloneConstraintName(partition.Name, src, dest)
+			if name == "" {
+				name = dest + "_" + partition.Name
+			}
+			statements = append(statements, fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s PARTITION OF %s %s", quoteIdentifier("postgres", name), quoteIdentifier("postgres", dest), partition.Bound))
+		}
+		return strings.Join(statements, ";") + ";"
+	}
+	return sql
+}
+
+var postgresReplicaIdentities = map[string]string{
+	"d": "DEFAULT",
+	"n": "NOTHING",
+	"f": "FULL",
+	"i": "USING INDEX",
+}
+
+// GetReplicaIdentity returns the replica identity of table used by logical
+// replication: DEFAULT, NOTHING, FULL or USING INDEX along with the name of
+// the index.
+func (p *Postgres) GetReplicaIdentity(table string) (identity, index string, err error) {
+	var rows []struct {
+		Identity string `db:"identity"`
+		Index    string `db:"index"`
+	}
+	err = p.client.Select(&rows, `SELECT c.relreplident::text AS identity, COALESCE(i.relname, '') AS index
+FROM pg_class c
+JOIN pg_namespace n ON n.oid = c.relnamespace
+LEFT JOIN pg_index ix ON ix.indrelid = c.oid AND ix.indisreplident
+LEFT JOIN pg_class i ON i.oid = ix.indexrelid
+WHERE n.nspname = 'public' AND c.relname = :table_name;`, map[string]any{
+		"table_name": table,
+	})
+	if err != nil || len(rows) == 0 {
+		return
+	}
+	return postgresReplicaIdentities[rows[0].Identity], rows[0].Index, nil
+}
+
+func postgresReplicaIdentitySQL(table, identity, index string) string {
+	switch identity {
+	case "", "DEFAULT":
+		return ""
+	case "USING INDEX":
+		if index == "" {
+			// The index of a clone couldn't be named, keep the default.
+			return ""
+		}
+		return fmt.Sprintf("ALTER TABLE %s REPLICA IDENTITY USING INDEX %s;", quoteIdentifier("postgres", table), quoteIdentifier("postgres", index))
+	}
+	return fmt.Sprintf("ALTER TABLE %s REPLICA IDENTITY %s;", quoteIdentifier("postgres", table), identity)
+}
+
 // GetTheIndices gets the indices for a table other than the primary key.
 // This has only been implemented for postgres.
-func (p *Postgres) GetTheIndices(table string) (incides []Indices, err error) {
+func (p *Postgres) GetTheIndices(table string) ([]Indices, error) {
+	if indices, ok := p.cache.getIndices(p.schema, table); ok {
+		return indices, nil
+	}
+	indices, err := p.getTheIndices(table)
+	if err == nil {
+		p.cache.setIndices(p.schema, table, indices)
+	}
+	return indices, err
+}
+
+func (p *Postgres) getTheIndices(table string) (incides []Indices, err error) {
 	err = p.client.Select(&incides, `
 SELECT
 	i.relname AS name,
-	json_agg(a.attname) AS columns,
-	ix.indisunique AS unique
+	(SELECT json_agg(pg_get_indexdef(ix.indexrelid, k, true) ORDER BY k) FROM generate_series(1, ix.indnkeyatts) AS k) AS columns,
+	ix.indisunique AS unique,
+	COALESCE(pg_get_expr(ix.indpred, ix.indrelid), '') AS "where",
+	(SELECT COALESCE(json_agg(pg_get_indexdef(ix.indexrelid, k, true) ORDER BY k), '[]') FROM generate_series(1, ix.indnkeyatts) AS k WHERE ix.indoption[k - 1] & 1 = 1) AS descending,
+	(SELECT COALESCE(json_agg(pg_get_indexdef(ix.indexrelid, k, true) ORDER BY k), '[]') FROM generate_series(ix.indnkeyatts + 1, ix.indnatts) AS k) AS "include"
 FROM
-	pg_class t,
-	pg_class i,
-	pg_index ix,
-	pg_attribute a
+	pg_index ix
+	JOIN pg_class i ON i.oid = ix.indexrelid
+	JOIN pg_class t ON t.oid = ix.indrelid
 WHERE
-	t.oid = ix.indrelid
-	AND i.oid = ix.indexrelid
-	AND a.attrelid = t.oid
-	AND a.attnum = ANY (ix.indkey)
-	AND t.relkind = 'r'
+	t.relkind = 'r'
 	AND NOT ix.indisprimary
 	AND t.relname = :table_name
-GROUP BY
-	i.relname,
-	ix.indisunique
 ORDER BY
 	i.relname;`, map[string]any{
 		"table_name": table,
@@ -273,17 +619,65 @@ ORDER BY
 	return
 }
 
+func (p *Postgres) GetConstraints(table string) (*Constraint, error) {
+	fields, err := p.GetFields(table)
+	if err != nil {
+		return nil, err
+	}
+	indices, err := p.GetTheIndices(table)
+	if err != nil {
+		return nil, err
+	}
+	foreignKeys, err := p.GetForeignKeys(table)
+	if err != nil {
+		return nil, err
+	}
+	checks, err := p.GetChecks(table)
+	if err != nil {
+		return nil, err
+	}
+	return newConstraint(fields, indices, foreignKeys, checks), nil
+}
+
+// GetChecks returns the CHECK constraints of table.
+func (p *Postgres) GetChecks(table string) (checks []Check, err error) {
+	err = p.client.Select(&checks, `SELECT con.conname AS name, pg_get_constraintdef(con.oid) AS expression
+FROM pg_constraint con
+JOIN pg_class c ON c.oid = con.conrelid
+JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE con.contype = 'c' AND n.nspname = 'public' AND c.relname = :table_name
+ORDER BY con.conname;`, map[string]any{
+		"table_name": table,
+	})
+	for i, check := range checks {
+		expression := strings.TrimPrefix(check.Expression, "CHECK ")
+		checks[i].Expression = strings.TrimSuffix(expression, " NOT VALID")
+	}
+	return
+}
+
 func (p *Postgres) GetCollection(table string) ([]map[string]any, error) {
+	if err := validateIdentifier(table); err != nil {
+		return nil, err
+	}
 	var rows []map[string]any
-	err := p.client.Select(&rows, "SELECT * FROM "+table)
+	err := p.client.Select(&rows, "SELECT * FROM "+quoteIdentifier(p.GetType(), table))
 	return rows, err
 }
 
-func (p *Postgres) Exec(sql string, values ...any) error {
+func (p *Postgres) Exec(sql string, values ...any) (int64, error) {
+	result, err := p.client.Exec(postgresQuotes(sql), values...)
+	p.cache.invalidateOnDDL(sql)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// postgresQuotes quotes identifiers with double quotes.
+func postgresQuotes(sql string) string {
 	sql = strings.ReplaceAll(sql, "`", `"`)
-	sql = strings.ReplaceAll(sql, `"/"`, `'/'`)
-	_, err := p.client.Exec(sql, values...)
-	return err
+	return strings.ReplaceAll(sql, `"/"`, `'/'`)
 }
 
 func (p *Postgres) GetRawCollection(query string, params ...map[string]any) ([]map[string]any, error) {
@@ -297,6 +691,7 @@ func (p *Postgres) GetRawCollection(query string, params ...map[string]any) ([]m
 			}
 		}
 		if len(param) > 0 {
+			query, param = expandSliceParams(query, param)
 			if err := p.client.Select(&rows, query, param); err != nil {
 				return nil, err
 			}
@@ -312,31 +707,92 @@ func (p *Postgres) GetRawCollection(query string, params ...map[string]any) ([]m
 	return rows, nil
 }
 
+// Explain returns the execution plan of query in JSON format.
+func (p *Postgres) Explain(query string) ([]map[string]any, error) {
+	return p.GetRawCollection("EXPLAIN (FORMAT JSON) " + query)
+}
+
+// QueryInto runs query and scans the rows into dest, a pointer to a slice of
+// structs with db tags.
+func (p *Postgres) QueryInto(dest any, query string, params ...map[string]any) error {
+	if len(params) > 0 && len(params[0]) > 0 {
+		query, param := expandSliceParams(query, params[0])
+		return p.client.Select(dest, query, param)
+	}
+	return p.client.Select(dest, query)
+}
+
+// EachRow calls fn with each row of query without loading the whole result.
+func (p *Postgres) EachRow(query string, fn func(row map[string]any) error) error {
+	return eachRow(p.client, query, fn)
+}
+
+// EachRowInTable calls fn with each row of table without loading the whole table.
+func (p *Postgres) EachRowInTable(table string, fn func(row map[string]any) error) error {
+	if err := validateIdentifier(table); err != nil {
+		return err
+	}
+	return eachRow(p.client, "SELECT * FROM "+quoteIdentifier(p.GetType(), table), fn)
+}
+
 func (p *Postgres) GetRawPaginatedCollection(query string, paging squealx.Paging, params ...map[string]any) squealx.PaginatedResponse {
 	var rows []map[string]any
 	return p.client.Paginate(query, &rows, paging, params...)
 }
 
 func (p *Postgres) GetPaginated(table string, paging squealx.Paging) squealx.PaginatedResponse {
+	if err := validateIdentifier(table); err != nil {
+		return squealx.PaginatedResponse{Error: err}
+	}
 	var rows []map[string]any
-	return p.client.Paginate("SELECT * FROM "+table, &rows, paging)
+	return p.client.Paginate("SELECT * FROM "+quoteIdentifier(p.GetType(), table), &rows, paging)
 }
 
 func (p *Postgres) GetSingle(table string) (map[string]any, error) {
+	if err := validateIdentifier(table); err != nil {
+		return nil, err
+	}
 	var row map[string]any
-	if err := p.client.Select(&row, fmt.Sprintf("SELECT * FROM %s LIMIT 1", table)); err != nil {
+	if err := p.client.Select(&row, fmt.Sprintf("SELECT * FROM %s LIMIT 1", quoteIdentifier(p.GetType(), table))); err != nil {
 		return nil, err
 	}
 	return row, nil
 }
 
+// DSN returns the DSN the data source connects with, with the password
+// masked when redact is set.
+func (p *Postgres) DSN(redact ...bool) string {
+	if len(redact) > 0 && redact[0] {
+		return redactDSN(p.dsn)
+	}
+	return p.dsn
+}
+
 func (p *Postgres) GetType() string {
 	return "postgres"
 }
 
+func (p *Postgres) Capabilities() Capability {
+	return Capability{
+		TransactionalDDL:  true,
+		DropColumn:        true,
+		AlterColumnType:   true,
+		CheckConstraints:  true,
+		DeferrableFK:      true,
+		MaterializedViews: true,
+		IfNotExists:       true,
+		Upsert:            true,
+	}
+}
+
 func getPostgresFieldAlterDataType(table string, f Field) string {
+	f = widenUnsigned(f)
+	f.DataType = postgresArrayType(f)
 	dataTypes := postgresDataTypes
 	defaultVal := ""
+	if f.GeneratedExpr != "" {
+		f.Default = nil
+	}
 	if f.Default != nil {
 		if v, ok := dataTypes[f.DataType]; ok {
 			if v == "BOOLEAN" {
@@ -351,7 +807,7 @@ func getPostgresFieldAlterDataType(table string, f Field) string {
 
 		switch def := f.Default.(type) {
 		case string:
-			if def == "CURRENT_TIMESTAMP" || strings.ToLower(def) == "true" || strings.ToLower(def) == "false" {
+			if def == "CURRENT_TIMESTAMP" || strings.ToLower(def) == "true" || strings.ToLower(def) == "false" || isPostgresExpressionDefault(def) {
 				defaultVal = fmt.Sprintf("DEFAULT %s", def)
 			} else {
 				defaultVal = fmt.Sprintf("DEFAULT '%s'", def)
@@ -365,7 +821,9 @@ func getPostgresFieldAlterDataType(table string, f Field) string {
 			f.DataType = "serial"
 		}
 	}
-	fieldName := f.Name
+	fieldName := quoteIdentifier("postgres", f.Name)
+	sequence := "DEFAULT nextval('" + quoteIdentifier("postgres", table+"_"+f.Name+"_seq") + "'::regclass)"
+	table = quoteIdentifier("postgres", table)
 	switch f.DataType {
 	case "int", "integer", "smallint", "bigint", "int2", "int4", "int8":
 		sql := fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET DATA TYPE %s USING %s::%s;", table, fieldName, dataTypes[f.DataType], fieldName, dataTypes[f.DataType])
@@ -377,7 +835,7 @@ func getPostgresFieldAlterDataType(table string, f Field) string {
 		if f.Length == 0 {
 			f.Length = 11
 		}
-		if f.Precision == 0 {
+		if f.Precision == 0 && !f.Unsigned {
 			f.Precision = 2
 		}
 		sql := fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET DATA TYPE %s(%d,%d) USING %s::%s;", table, fieldName, dataTypes[f.DataType], f.Length, f.Precision, fieldName, dataTypes[f.DataType])
@@ -396,14 +854,18 @@ func getPostgresFieldAlterDataType(table string, f Field) string {
 		return sql
 	case "serial":
 		sql := fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET DATA TYPE %s USING %s::integer;", table, fieldName, "integer", fieldName)
-		sql += fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET %s;", table, fieldName, "DEFAULT nextval('"+table+"_"+fieldName+"_seq'::regclass)")
+		sql += fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET %s;", table, fieldName, sequence)
 		return sql
 	case "bigserial":
 		sql := fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET DATA TYPE %s USING %s::bigint;", table, fieldName, "bigint", fieldName)
-		sql += fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET %s;", table, fieldName, "DEFAULT nextval('"+table+"_"+fieldName+"_seq'::regclass)")
+		sql += fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET %s;", table, fieldName, sequence)
 		return sql
 	default:
-		sql := fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET DATA TYPE %s USING %s::%s;", table, fieldName, dataTypes[f.DataType], fieldName, dataTypes[f.DataType])
+		dataType, ok := dataTypes[f.DataType]
+		if !ok {
+			dataType = f.DataType
+		}
+		sql := fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET DATA TYPE %s USING %s::%s;", table, fieldName, dataType, fieldName, dataType)
 		if defaultVal != "" {
 			sql += fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET %s;", table, fieldName, defaultVal)
 		}
@@ -411,56 +873,237 @@ func getPostgresFieldAlterDataType(table string, f Field) string {
 	}
 }
 
+var usingCast = regexp.MustCompile(` USING [^;]+;`)
+
+// getCockroachFieldAlterDataType is getPostgresFieldAlterDataType for
+// CockroachDB, which changes a column type without a USING cast and has no
+// serial sequences, generating the values with unique_rowid() instead.
+func getCockroachFieldAlterDataType(table string, f Field) string {
+	if strings.ToUpper(f.Extra) == "AUTO_INCREMENT" || f.DataType == "serial" || f.DataType == "bigserial" {
+		table, column := quoteIdentifier("postgres", table), quoteIdentifier("postgres", f.Name)
+		sql := fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET DATA TYPE INT8;", table, column)
+		return sql + fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET DEFAULT unique_rowid();", table, column)
+	}
+	return usingCast.ReplaceAllString(getPostgresFieldAlterDataType(table, f), ";")
+}
+
+func postgresEnumType(table, field string) string {
+	return table + "_" + field
+}
+
+// postgresEnumSQL creates the enum type unless it already exists, so the
+// statements of a repeated migration still run.
+func postgresEnumSQL(typeName string, values []string) string {
+	return fmt.Sprintf("DO $$ BEGIN CREATE TYPE %s AS ENUM (%s); EXCEPTION WHEN duplicate_object THEN NULL; END $$;", typeName, quoteEnumValues(values))
+}
+
+var (
+	castDefault     = regexp.MustCompile(`^'((?:[^']|'')*)'::([A-Za-z_][\w ."]*(?:\[\])?)$`)
+	functionDefault = regexp.MustCompile(`^[A-Za-z_][\w.]*\(.*\)$`)
+)
+
+// postgresPlainCasts are the casts Postgres adds to literal defaults of
+// builtin types. They're redundant, unlike casts to enums or jsonb, which the
+// literal needs to keep its meaning.
+var postgresPlainCasts = []string{
+	"text", "character varying", "character", "bpchar",
+	"smallint", "integer", "bigint", "numeric", "real", "double precision", "boolean",
+	"date", "time without time zone", "timestamp without time zone", "timestamp with time zone",
+}
+
+// normalizePostgresDefault strips redundant casts from a column default as
+// reported by Postgres: the cast of literals of builtin types and the regclass
+// cast of sequence defaults. Other casts such as 'active'::status or
+// '{}'::jsonb are kept.
+func normalizePostgresDefault(def string) string {
+	if match := castDefault.FindStringSubmatch(def); match != nil && contains(postgresPlainCasts, match[2]) {
+		return strings.ReplaceAll(match[1], "''", "'")
+	}
+	if strings.HasPrefix(def, "nextval(") {
+		return strings.ReplaceAll(def, "::regclass", "")
+	}
+	return def
+}
+
+// isPostgresExpressionDefault reports whether def is a function call or a cast
+// literal, which are emitted as is instead of quoted.
+func isPostgresExpressionDefault(def string) bool {
+	return castDefault.MatchString(def) || functionDefault.MatchString(def)
+}
+
+// postgresArrayType returns the data type of f, spelled as element[] for array
+// columns.
+func postgresArrayType(f Field) string {
+	if f.ArrayOf == "" {
+		return f.DataType
+	}
+	if v, ok := postgresDataTypes[f.ArrayOf]; ok {
+		return v + "[]"
+	}
+	return f.ArrayOf + "[]"
+}
+
+// widenUnsigned maps an unsigned integer to the next larger signed type, as
+// Postgres has no unsigned integers.
+func widenUnsigned(f Field) Field {
+	if !f.Unsigned {
+		return f
+	}
+	switch strings.ToLower(f.DataType) {
+	case "tinyint":
+		f.DataType = "smallint"
+	case "smallint", "int2":
+		f.DataType = "int"
+	case "mediumint", "int", "integer", "int4":
+		f.DataType = "bigint"
+	case "bigint", "int8":
+		f.DataType = "numeric"
+		f.Length = 20
+		f.Precision = 0
+	}
+	return f
+}
+
+func postgresIndexSQL(table string, index Indices) string {
+	query := postgresQueries["create_index"]
+	if index.Unique {
+		query = postgresQueries["create_unique_index"]
+	}
+	sql := fmt.Sprintf(query, indexName(table, index), quoteIdentifier("postgres", table), strings.Join(indexKeyParts(index, false), ", "))
+	if len(index.Include) > 0 {
+		sql = strings.TrimSuffix(sql, ";") + " INCLUDE (" + strings.Join(index.Include, ", ") + ");"
+	}
+	if index.Where != "" {
+		sql = strings.TrimSuffix(sql, ";") + " WHERE " + index.Where + ";"
+	}
+	return sql
+}
+
+// postgresIndicesEqual reports whether the existing index already matches index.
+func postgresIndicesEqual(existing, index Indices) bool {
+	return indexColumnsEqual(existing.Columns, index.Columns) &&
+		existing.Unique == index.Unique &&
+		sameIndexColumns(existing.Descending, index.Descending) &&
+		sameIndexColumns(existing.Include, index.Include) &&
+		normalizeExpression(existing.Where) == normalizeExpression(index.Where)
+}
+
+// postgresSerialTypes maps the serial pseudo types to the integer type of the
+// column they create.
+var postgresSerialTypes = map[string]string{
+	"smallserial": "smallint",
+	"serial2":     "smallint",
+	"serial":      "integer",
+	"serial4":     "integer",
+	"bigserial":   "bigint",
+	"serial8":     "bigint",
+}
+
+// postgresFieldsEqual reports whether the existing column already matches f.
+// Defaults don't apply to generated columns, their expression is compared instead.
+func postgresFieldsEqual(existing, f Field) bool {
+	f = widenUnsigned(f)
+	if isPostgresUserType(f.DataType) && isPostgresUserType(existing.DataType) {
+		// User defined types such as enums are compared by name, they have
+		// no length.
+		return strings.EqualFold(existing.DataType, f.DataType) &&
+			defaultsEqual(postgresUncastDefault(existing.Default, existing.DataType), f.Default)
+	}
+	if base, ok := postgresSerialTypes[strings.ToLower(f.DataType)]; ok && f.Default == nil {
+		// A serial column reads back as an integer defaulting to its sequence.
+		def, _ := existing.Default.(string)
+		return postgresDataTypes[existing.DataType] == postgresDataTypes[base] && strings.HasPrefix(def, "nextval(")
+	}
+	if postgresDataTypes[existing.DataType] != postgresDataTypes[f.DataType] ||
+		(existing.ArrayOf != "" || f.ArrayOf != "") && postgresArrayType(existing) != postgresArrayType(f) ||
+		existing.Length != f.Length {
+		return false
+	}
+	if existing.GeneratedExpr != "" || f.GeneratedExpr != "" {
+		return normalizeExpression(existing.GeneratedExpr) == normalizeExpression(f.GeneratedExpr)
+	}
+	return defaultsEqual(existing.Default, f.Default)
+}
+
+// isPostgresUserType reports whether dataType names a user defined type, such
+// as the enum types of postgresEnumType, rather than a builtin one.
+func isPostgresUserType(dataType string) bool {
+	_, builtin := postgresDataTypes[dataType]
+	return dataType != "" && !builtin && !contains([]string{"enum", "ARRAY", "USER-DEFINED"}, dataType)
+}
+
+// postgresUncastDefault returns the literal of a default Postgres reports
+// cast to the type of its column, such as 'active'::users_status.
+func postgresUncastDefault(def any, dataType string) any {
+	s, ok := def.(string)
+	if !ok || !strings.HasSuffix(s, "::"+dataType) {
+		return def
+	}
+	return strings.Trim(strings.TrimSuffix(s, "::"+dataType), "'")
+}
+
+// postgresEnumField maps an enum field, which MySQL reports with its values,
+// to the enum type created for its column.
+func postgresEnumField(table string, f Field) Field {
+	if len(f.EnumValues) > 0 || strings.EqualFold(f.DataType, "enum") {
+		f.DataType = postgresEnumType(table, f.Name)
+		f.Length = 0
+	}
+	return f
+}
+
 func (p *Postgres) alterFieldSQL(table string, f, existingField Field) string {
-	newSQL := getPostgresFieldAlterDataType(table, f)
-	existingSQL := getPostgresFieldAlterDataType(table, existingField)
+	alterDataType := getPostgresFieldAlterDataType
+	if p.cockroach {
+		alterDataType = getCockroachFieldAlterDataType
+	}
+	newSQL := alterDataType(table, f)
+	existingSQL := alterDataType(table, existingField)
 	if newSQL != existingSQL {
 		return newSQL
 	}
 	return ""
 }
 
-func (p *Postgres) createSQL(table string, newFields []Field, indices ...Indices) (string, error) {
+func (p *Postgres) createSQL(table string, newFields []Field, constraints *Constraint, indices ...Indices) (string, error) {
 	var sql string
-	var query, comments, indexQuery, primaryKeys []string
+	var query, enums, comments, storages, indexQuery []string
 	for _, field := range newFields {
 		fieldName := field.Name
-		if strings.ToUpper(field.Key) == "PRI" {
-			primaryKeys = append(primaryKeys, fieldName)
+		if len(field.EnumValues) > 0 {
+			field.DataType = postgresEnumType(table, fieldName)
+			enums = append(enums, postgresEnumSQL(field.DataType, field.EnumValues))
 		}
 		query = append(query, p.FieldAsString(field, "column"))
 		if field.Comment != "" {
-			comment := "COMMENT ON COLUMN " + table + "." + fieldName + " IS '" + strings.ReplaceAll(field.Comment, "'", `"`) + "';"
+			comment := "COMMENT ON COLUMN " + quoteIdentifier(p.GetType(), table+"."+fieldName) + " IS '" + strings.ReplaceAll(field.Comment, "'", `"`) + "';"
 			comments = append(comments, comment)
 		}
+		if storage := postgresStorageSQL(table, field); storage != "" {
+			storages = append(storages, storage)
+		}
 	}
 	if len(indices) > 0 {
 		for _, index := range indices {
-			if index.Name == "" {
-				index.Name = "idx_" + table + "_" + strings.Join(index.Columns, "_")
-			}
-			switch index.Unique {
-			case true:
-				query := fmt.Sprintf(postgresQueries["create_unique_index"], index.Name, table,
-					strings.Join(index.Columns, ", "))
-				indexQuery = append(indexQuery, query)
-			case false:
-				query := fmt.Sprintf(postgresQueries["create_index"], index.Name, table,
-					strings.Join(index.Columns, ", "))
-				indexQuery = append(indexQuery, query)
-			}
+			indexQuery = append(indexQuery, postgresIndexSQL(table, index))
 		}
 	}
-	if len(primaryKeys) > 0 {
+	if primaryKeys := primaryKeyColumns(newFields, constraints); len(primaryKeys) > 0 {
+		for i, primaryKey := range primaryKeys {
+			primaryKeys[i] = quoteIdentifier(p.GetType(), primaryKey)
+		}
 		query = append(query, " PRIMARY KEY ("+strings.Join(primaryKeys, ", ")+")")
 	}
 	if len(query) > 0 {
 		fieldsToUpdate := strings.Join(query, ", ")
-		sql = fmt.Sprintf(postgresQueries["create_table"], table) + " (" + fieldsToUpdate + ");"
+		sql = strings.Join(enums, "") + fmt.Sprintf(postgresQueries["create_table"], quoteIdentifier(p.GetType(), table)) + " (" + fieldsToUpdate + ");"
 	}
 	if len(comments) > 0 {
 		sql += strings.Join(comments, "")
 	}
+	if len(storages) > 0 {
+		sql += strings.Join(storages, "")
+	}
 	if len(indexQuery) > 0 {
 		sql += strings.Join(indexQuery, "")
 	}
@@ -469,7 +1112,7 @@ func (p *Postgres) createSQL(table string, newFields []Field, indices ...Indices
 
 func (p *Postgres) alterSQL(table string, newFields []Field, newIndices ...Indices) (string, error) {
 	var sql []string
-	alterTable := "ALTER TABLE " + table
+	alterTable := "ALTER TABLE " + quoteIdentifier(p.GetType(), table)
 	existingFields, err := p.GetFields(table)
 	if err != nil {
 		return "", err
@@ -478,49 +1121,80 @@ func (p *Postgres) alterSQL(table string, newFields []Field, newIndices ...Indic
 	if err != nil {
 		return "", err
 	}
+	renames, err := columnRenames(newFields, existingFields)
+	if err != nil {
+		return "", err
+	}
+	for _, rename := range renames {
+		sql = append(sql, fmt.Sprintf("%s RENAME COLUMN %s TO %s;", alterTable, quoteIdentifier(p.GetType(), rename.From), quoteIdentifier(p.GetType(), rename.To)))
+	}
+	existingFields = renameColumns(existingFields, renames)
 	for _, newField := range newFields {
 		if newField.IsNullable == "" {
 			newField.IsNullable = "YES"
 		}
+		// The renames already ran, so the field is compared with the column
+		// under its new name, which also covers a rename that ran before.
+		newField.OldName = ""
 		fieldExists := false
-		if newField.OldName == "" {
-			fieldName := newField.Name
-			for _, existingField := range existingFields {
-				if existingField.Name == fieldName {
-					fieldExists = true
-					if postgresDataTypes[existingField.DataType] != postgresDataTypes[newField.DataType] ||
-						existingField.Length != newField.Length ||
-						existingField.Default != newField.Default {
-						qry := p.alterFieldSQL(table, newField, existingField)
-						if qry != "" {
-							sql = append(sql, qry)
-						}
+		fieldName := newField.Name
+		for _, existingField := range existingFields {
+			if existingField.Name == fieldName {
+				fieldExists = true
+				if enumField := postgresEnumField(table, newField); enumField.DataType != newField.DataType {
+					if !strings.EqualFold(existingField.DataType, enumField.DataType) && len(newField.EnumValues) > 0 {
+						sql = append(sql, postgresEnumSQL(enumField.DataType, newField.EnumValues))
+					}
+					newField = enumField
+				}
+				if newField.GeneratedExpr != "" && !postgresFieldsEqual(existingField, newField) {
+					// The expression of a generated column can't be altered, it's
+					// recreated instead as it holds no data of its own.
+					sql = append(sql, fmt.Sprintf("%s DROP COLUMN %s;", alterTable, quoteIdentifier(p.GetType(), fieldName)))
+					sql = append(sql, alterTable+" "+p.FieldAsString(newField, "add_column")+";")
+					continue
+				}
+				if existingField.GeneratedExpr != "" && newField.GeneratedExpr == "" {
+					sql = append(sql, fmt.Sprintf("%s ALTER COLUMN %s DROP EXPRESSION;", alterTable, quoteIdentifier(p.GetType(), fieldName)))
+					existingField.GeneratedExpr = ""
+					existingField.Stored = false
+				}
+				if !postgresFieldsEqual(existingField, newField) {
+					qry := p.alterFieldSQL(table, newField, existingField)
+					if qry != "" {
+						sql = append(sql, qry)
 					}
-					if existingField.IsNullable != newField.IsNullable {
-						if newField.IsNullable == "YES" {
-							sql = append(sql, fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s DROP NOT NULL;", table, fieldName))
-						} else {
-							sql = append(sql, fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET NOT NULL;", table, fieldName))
-						}
+				}
+				if existingField.IsNullable != newField.IsNullable {
+					if newField.IsNullable == "YES" {
+						sql = append(sql, fmt.Sprintf("%s ALTER COLUMN %s DROP NOT NULL;", alterTable, quoteIdentifier(p.GetType(), fieldName)))
+					} else {
+						sql = append(sql, fmt.Sprintf("%s ALTER COLUMN %s SET NOT NULL;", alterTable, quoteIdentifier(p.GetType(), fieldName)))
 					}
+				}
 
-					if existingField.Comment != newField.Comment {
-						sql = append(sql, "COMMENT ON COLUMN "+table+"."+fieldName+" IS '"+strings.ReplaceAll(newField.Comment, "'", `"`)+"';")
+				if existingField.Comment != newField.Comment {
+					sql = append(sql, "COMMENT ON COLUMN "+quoteIdentifier(p.GetType(), table+"."+fieldName)+" IS '"+strings.ReplaceAll(newField.Comment, "'", `"`)+"';")
+				}
+				if !strings.EqualFold(existingField.Storage, newField.Storage) || !strings.EqualFold(existingField.Compression, newField.Compression) {
+					if storage := postgresStorageSQL(table, newField); storage != "" {
+						sql = append(sql, storage)
 					}
 				}
 			}
 		}
 		if !fieldExists {
+			if len(newField.EnumValues) > 0 {
+				newField.DataType = postgresEnumType(table, newField.Name)
+				sql = append(sql, postgresEnumSQL(newField.DataType, newField.EnumValues))
+			}
 			qry := alterTable + " " + p.FieldAsString(newField, "add_column") + ";"
 			if qry != "" {
 				sql = append(sql, qry)
 			}
-		}
-	}
-	for _, newField := range newFields {
-		fieldName := newField.Name
-		if newField.OldName != "" {
-			sql = append(sql, alterTable+` RENAME COLUMN "`+newField.OldName+`" TO "`+fieldName+`";`)
+			if storage := postgresStorageSQL(table, newField); storage != "" {
+				sql = append(sql, storage)
+			}
 		}
 	}
 	// create a map to keep track of existing indices by name
@@ -529,38 +1203,44 @@ func (p *Postgres) alterSQL(table string, newFields []Field, newIndices ...Indic
 		existingIndicesMap[existingIndex.Name] = existingIndex
 	}
 	for _, newIndex := range newIndices {
-		// if new index has no name, generate one
-		if newIndex.Name == "" {
-			newIndex.Name = "idx_" + table + "_" + strings.Join(newIndex.Columns, "_")
-		}
+		newIndex.Name = indexName(table, newIndex)
 		existingIndex, indexExists := existingIndicesMap[newIndex.Name]
 		if indexExists {
 			// compare the columns
 			// if they are different, drop the index and create a new one
-			if !reflect.DeepEqual(existingIndex.Columns, newIndex.Columns) {
-				sql = append(sql, fmt.Sprintf("DROP INDEX %s;", existingIndex.Name))
-				switch newIndex.Unique {
-				case true:
-					sql = append(sql, fmt.Sprintf(postgresQueries["create_unique_index"], newIndex.Name, table, strings.Join(newIndex.Columns, ", ")))
-				case false:
-					sql = append(sql, fmt.Sprintf(postgresQueries["create_index"], newIndex.Name, table, strings.Join(newIndex.Columns, ", ")))
-				}
+			if !postgresIndicesEqual(existingIndex, newIndex) {
+				sql = append(sql, fmt.Sprintf("DROP INDEX %s;", quoteIdentifier(p.GetType(), existingIndex.Name)))
+				sql = append(sql, postgresIndexSQL(table, newIndex))
 			}
 			// Remove existing index from map
 			delete(existingIndicesMap, newIndex.Name)
 		} else {
 			// New index with provided name and columns
-			switch newIndex.Unique {
-			case true:
-				sql = append(sql, fmt.Sprintf(postgresQueries["create_unique_index"], newIndex.Name, table, strings.Join(newIndex.Columns, ", ")))
-			case false:
-				sql = append(sql, fmt.Sprintf(postgresQueries["create_index"], newIndex.Name, table, strings.Join(newIndex.Columns, ", ")))
-			}
+			sql = append(sql, postgresIndexSQL(table, newIndex))
 		}
 	}
-	// drop any remaining indices in the map
-	for _, existingIndex := range existingIndicesMap {
-		sql = append(sql, fmt.Sprintf("DROP INDEX %s;", existingIndex.Name))
+	if p.config.DropIndices {
+		// Indices backing a unique or exclusion constraint can only be
+		// dropped with the constraint.
+		var constraints []string
+		err = p.client.Select(&constraints, "SELECT conname FROM pg_constraint WHERE conrelid = CAST(:table_name AS regclass) AND contype IN ('u', 'x');", map[string]any{
+			"table_name": table,
+		})
+		if err != nil {
+			return "", err
+		}
+		names := make([]string, 0, len(existingIndicesMap))
+		for name := range existingIndicesMap {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if contains(constraints, name) {
+				sql = append(sql, fmt.Sprintf("%s DROP CONSTRAINT %s;", alterTable, quoteIdentifier(p.GetType(), name)))
+			} else {
+				sql = append(sql, fmt.Sprintf("DROP INDEX %s;", quoteIdentifier(p.GetType(), name)))
+			}
+		}
 	}
 	if len(sql) > 0 {
 		return strings.Join(sql, ""), nil
@@ -568,6 +1248,36 @@ func (p *Postgres) alterSQL(table string, newFields []Field, newIndices ...Indic
 	return "", nil
 }
 
+// RecreateTable returns the statements rebuilding table with fields, for changes
+// ALTER TABLE can't express. The rows of the kept columns are copied over.
+func (p *Postgres) RecreateTable(table string, fields []Field, constraints *Constraint) (string, error) {
+	existing, err := p.GetFields(table)
+	if err != nil {
+		return "", err
+	}
+	temp := table + "_recreate"
+	// Index names are unique per schema, so the indices are created under a
+	// temporary name and renamed once the old table is dropped.
+	var indices []Indices
+	rename := fmt.Sprintf("ALTER TABLE %s RENAME TO %s;", quoteIdentifier(p.GetType(), temp), quoteIdentifier(p.GetType(), table))
+	if constraints != nil {
+		for _, index := range constraints.Indices {
+			if index.Name == "" {
+				index.Name = "idx_" + table + "_" + strings.Join(index.Columns, "_")
+			}
+			name := index.Name
+			index.Name = name + "_recreate"
+			indices = append(indices, index)
+			rename += fmt.Sprintf("ALTER INDEX %s RENAME TO %s;", quoteIdentifier(p.GetType(), index.Name), quoteIdentifier(p.GetType(), name))
+		}
+	}
+	create, err := p.createSQL(temp, fields, constraints, indices...)
+	if err != nil {
+		return "", err
+	}
+	return recreateTableSQL(p.GetType(), table, temp, existing, fields, constraints, create, rename), nil
+}
+
 func (p *Postgres) GenerateSQL(table string, newFields []Field, indices ...Indices) (string, error) {
 	sources, err := p.GetSources()
 	if err != nil {
@@ -581,7 +1291,7 @@ func (p *Postgres) GenerateSQL(table string, newFields []Field, indices ...Indic
 		}
 	}
 	if !sourceExists {
-		return p.createSQL(table, newFields, indices...)
+		return p.createSQL(table, newFields, nil, indices...)
 	}
 	return p.alterSQL(table, newFields, indices...)
 }
@@ -595,10 +1305,47 @@ func (p *Postgres) Migrate(table string, dst DataSource) error {
 	if err != nil {
 		return err
 	}
+	if _, ok := dst.(*Postgres); ok {
+		identity, index, err := p.GetReplicaIdentity(table)
+		if err != nil {
+			return err
+		}
+		sql += postgresReplicaIdentitySQL(table, identity, index)
+	}
 	fmt.Println(sql)
 	return nil
 }
 
+// ReadOnly returns a copy of the data source that routes every query to the
+// read replicas, to keep heavy introspection off the primary.
+func (p *Postgres) ReadOnly() (DataSource, error) {
+	client, err := p.routes.route(p.client, true, p.logger, p.disableLog)
+	if err != nil {
+		return nil, err
+	}
+	source := *p
+	source.client = client
+	return &source, nil
+}
+
+// WriteOnly returns a copy of the data source that routes every query to the primaries.
+func (p *Postgres) WriteOnly() (DataSource, error) {
+	client, err := p.routes.route(p.client, false, p.logger, p.disableLog)
+	if err != nil {
+		return nil, err
+	}
+	source := *p
+	source.client = client
+	return &source, nil
+}
+
+func (p *Postgres) Ping() error {
+	if p.client == nil {
+		return errors.New("connection not established")
+	}
+	return p.client.Ping()
+}
+
 func (p *Postgres) Close() error {
 	return p.client.Close()
 }
@@ -608,6 +1355,7 @@ func (p *Postgres) Begin() (squealx.SQLTx, error) {
 }
 
 func (p *Postgres) FieldAsString(f Field, action string) string {
+	f = widenUnsigned(f)
 	sqlPattern := postgresQueries
 	dataTypes := postgresDataTypes
 	nullable := "NULL"
@@ -631,7 +1379,7 @@ func (p *Postgres) FieldAsString(f Field, action string) string {
 		}
 		switch def := f.Default.(type) {
 		case string:
-			if contains(builtInFunctions, strings.ToLower(def)) {
+			if contains(builtInFunctions, strings.ToLower(def)) || isPostgresExpressionDefault(def) {
 				defaultVal = fmt.Sprintf("DEFAULT %s", def)
 			} else {
 				defaultVal = fmt.Sprintf("DEFAULT '%s'", def)
@@ -656,7 +1404,13 @@ func (p *Postgres) FieldAsString(f Field, action string) string {
 			}
 		}
 	}
-	fieldName := f.Name
+	if f.GeneratedExpr != "" {
+		// Postgres only supports stored generated columns.
+		nullable = "GENERATED ALWAYS AS (" + f.GeneratedExpr + ") STORED " + nullable
+		defaultVal = ""
+	}
+	f.DataType = postgresArrayType(f)
+	fieldName := quoteIdentifier(p.GetType(), f.Name)
 	switch f.DataType {
 	case "string", "varchar", "character varying", "char", "character":
 		if f.Length == 0 {
@@ -671,14 +1425,19 @@ func (p *Postgres) FieldAsString(f Field, action string) string {
 		if f.Length == 0 {
 			f.Length = 11
 		}
-		if f.Precision == 0 {
+		if f.Precision == 0 && !f.Unsigned {
 			f.Precision = 2
 		}
 		changeColumn := sqlPattern[action] + "(%d, %d) %s %s %s %s %s"
 		return strings.TrimSpace(space.ReplaceAllString(fmt.Sprintf(changeColumn, fieldName, dataTypes[f.DataType], f.Length, f.Precision, nullable, primaryKey, autoIncrement, defaultVal, comment), " "))
 	default:
+		dataType, ok := dataTypes[f.DataType]
+		if !ok {
+			// User defined types such as enums are referenced by name.
+			dataType = f.DataType
+		}
 		changeColumn := sqlPattern[action] + " %s %s %s %s %s"
-		return strings.TrimSpace(space.ReplaceAllString(fmt.Sprintf(changeColumn, fieldName, dataTypes[f.DataType], nullable, primaryKey, autoIncrement, defaultVal, comment), " "))
+		return strings.TrimSpace(space.ReplaceAllString(fmt.Sprintf(changeColumn, fieldName, dataType, nullable, primaryKey, autoIncrement, defaultVal, comment), " "))
 	}
 }
 
@@ -690,5 +1449,6 @@ func NewPostgres(id, dsn, database string, disableLog bool, pooling ConnectionPo
 		client:     nil,
 		disableLog: disableLog,
 		pooling:    pooling,
+		routes:     newClientRoutes(),
 	}
 }
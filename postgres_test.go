@@ -0,0 +1,258 @@
+package metadata
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPostgresEnumFields(t *testing.T) {
+	mysqlEnum := Field{Name: "status", DataType: "enum", Length: 8, EnumValues: []string{"active", "inactive"}, Default: "active"}
+	tests := []struct {
+		name     string
+		existing Field
+		field    Field
+		equal    bool
+	}{
+		{
+			name:     "recloned enum",
+			existing: Field{Name: "status", DataType: "users_status", Default: "'active'::users_status"},
+			field:    postgresEnumField("users", mysqlEnum),
+			equal:    true,
+		},
+		{
+			name:     "changed default",
+			existing: Field{Name: "status", DataType: "users_status", Default: "'inactive'::users_status"},
+			field:    postgresEnumField("users", mysqlEnum),
+		},
+		{
+			name:     "text to enum",
+			existing: Field{Name: "status", DataType: "character varying", Length: 8},
+			field:    postgresEnumField("users", mysqlEnum),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := postgresFieldsEqual(tt.existing, tt.field); got != tt.equal {
+				t.Fatalf("postgresFieldsEqual(%+v, %+v) = %v, want %v", tt.existing, tt.field, got, tt.equal)
+			}
+		})
+	}
+	p := &Postgres{}
+	sql := p.alterFieldSQL("users", postgresEnumField("users", mysqlEnum), Field{Name: "status", DataType: "character varying", Length: 8})
+	if !strings.Contains(sql, `ALTER TABLE "users" ALTER COLUMN "status" SET DATA TYPE users_status USING "status"::users_status`) {
+		t.Fatalf("unexpected alter statement %q", sql)
+	}
+}
+
+func TestPostgresEnumSQLIsRepeatable(t *testing.T) {
+	sql := postgresEnumSQL("users_status", []string{"active", "inactive"})
+	if !strings.Contains(sql, "EXCEPTION WHEN duplicate_object") {
+		t.Fatalf("enum type creation isn't guarded: %q", sql)
+	}
+	if statements := splitStatements(sql); len(statements) != 1 {
+		t.Fatalf("expected one statement, got %q", statements)
+	}
+}
+
+// cachedPostgres returns a Postgres data source whose schema cache holds the
+// fields of table, so the alter path runs without a database.
+func cachedPostgres(table string, fields []Field) *Postgres {
+	p := &Postgres{cache: newSchemaCache()}
+	p.cache.setFields(p.GetDBName(), table, fields)
+	p.cache.setIndices(p.schema, table, nil)
+	return p
+}
+
+func TestCanonicalDefault(t *testing.T) {
+	tests := []struct {
+		a, b  any
+		equal bool
+	}{
+		{a: "now()", b: "CURRENT_TIMESTAMP", equal: true},
+		{a: "CURRENT_TIMESTAMP()", b: "transaction_timestamp()", equal: true},
+		{a: "(now())", b: "current_timestamp", equal: true},
+		{a: "gen_random_uuid()", b: "uuid_generate_v4()", equal: true},
+		{a: "nextval('users_id_seq'::regclass)", b: "nextval('public.users_id_seq')", equal: true},
+		{a: "'now()'", b: "now()"},
+		{a: "0", b: 0, equal: true},
+		{a: "CURRENT_DATE", b: "now()"},
+		{a: "'a'", b: "'A'"},
+		{a: nil, b: "NULL"},
+		{a: nil, b: nil, equal: true},
+	}
+	for _, tt := range tests {
+		if got := defaultsEqual(tt.a, tt.b); got != tt.equal {
+			t.Fatalf("defaultsEqual(%v, %v) = %v, want %v (canonical %q and %q)", tt.a, tt.b, got, tt.equal, canonicalDefault(tt.a), canonicalDefault(tt.b))
+		}
+	}
+}
+
+func TestPostgresEquivalentDefaultsProduceNoAlter(t *testing.T) {
+	existing := []Field{
+		{Name: "id", DataType: "uuid", IsNullable: "NO", Default: "gen_random_uuid()"},
+		{Name: "created_at", DataType: "timestamp without time zone", IsNullable: "NO", Default: "now()"},
+	}
+	fields := []Field{
+		{Name: "id", DataType: "uuid", IsNullable: "NO", Default: "uuid_generate_v4()"},
+		{Name: "created_at", DataType: "timestamp", IsNullable: "NO", Default: "CURRENT_TIMESTAMP"},
+	}
+	sql, err := cachedPostgres("events", existing).alterSQL("events", fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sql != "" {
+		t.Fatalf("expected no statements, got %q", sql)
+	}
+}
+
+func TestPostgresFieldsEqualSpelling(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing Field
+		field    Field
+		equal    bool
+	}{
+		{name: "varchar", existing: Field{DataType: "character varying", Length: 20}, field: Field{DataType: "varchar", Length: 20}, equal: true},
+		{name: "varchar length", existing: Field{DataType: "character varying", Length: 20}, field: Field{DataType: "varchar", Length: 30}},
+		{name: "timestamp", existing: Field{DataType: "timestamp without time zone"}, field: Field{DataType: "timestamp"}, equal: true},
+		{name: "timestamptz", existing: Field{DataType: "timestamp with time zone"}, field: Field{DataType: "timestamp"}},
+		{name: "int array", existing: Field{DataType: "ARRAY", ArrayOf: "int4"}, field: Field{DataType: "array", ArrayOf: "int4"}, equal: true},
+		{name: "array element", existing: Field{DataType: "ARRAY", ArrayOf: "int4"}, field: Field{DataType: "array", ArrayOf: "text"}},
+		{name: "array to scalar", existing: Field{DataType: "ARRAY", ArrayOf: "text"}, field: Field{DataType: "text"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := postgresFieldsEqual(tt.existing, tt.field); got != tt.equal {
+				t.Fatalf("postgresFieldsEqual(%+v, %+v) = %v, want %v", tt.existing, tt.field, got, tt.equal)
+			}
+		})
+	}
+}
+
+func TestPostgresSerialProducesNoAlter(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing Field
+		field    Field
+	}{
+		{
+			name:     "serial",
+			existing: Field{Name: "id", DataType: "integer", IsNullable: "NO", Default: "nextval('users_id_seq'::regclass)"},
+			field:    Field{Name: "id", DataType: "serial", IsNullable: "NO"},
+		},
+		{
+			name:     "bigserial",
+			existing: Field{Name: "id", DataType: "bigint", IsNullable: "NO", Default: "nextval('users_id_seq'::regclass)"},
+			field:    Field{Name: "id", DataType: "bigserial", IsNullable: "NO"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql, err := cachedPostgres("users", []Field{tt.existing}).alterSQL("users", []Field{tt.field})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sql != "" {
+				t.Fatalf("expected no statements, got %q", sql)
+			}
+		})
+	}
+	if postgresFieldsEqual(Field{DataType: "integer"}, Field{DataType: "serial"}) {
+		t.Fatal("an integer without a sequence default should not equal serial")
+	}
+}
+
+func TestCockroachAlterDataType(t *testing.T) {
+	tests := []struct {
+		name      string
+		field     Field
+		postgres  string
+		cockroach string
+	}{
+		{
+			name:      "varchar",
+			field:     Field{Name: "name", DataType: "varchar", Length: 50, IsNullable: "YES"},
+			postgres:  `ALTER TABLE "users" ALTER COLUMN "name" SET DATA TYPE VARCHAR(50) USING "name"::VARCHAR;`,
+			cockroach: `ALTER TABLE "users" ALTER COLUMN "name" SET DATA TYPE VARCHAR(50);`,
+		},
+		{
+			name:      "serial",
+			field:     Field{Name: "id", DataType: "serial", IsNullable: "NO"},
+			postgres:  `ALTER TABLE "users" ALTER COLUMN "id" SET DATA TYPE integer USING "id"::integer;`,
+			cockroach: `ALTER TABLE "users" ALTER COLUMN "id" SET DATA TYPE INT8;ALTER TABLE "users" ALTER COLUMN "id" SET DEFAULT unique_rowid();`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getPostgresFieldAlterDataType("users", tt.field); !strings.HasPrefix(got, tt.postgres) {
+				t.Errorf("postgres = %q, want prefix %q", got, tt.postgres)
+			}
+			got := getCockroachFieldAlterDataType("users", tt.field)
+			if !strings.HasPrefix(got, tt.cockroach) {
+				t.Errorf("cockroach = %q, want prefix %q", got, tt.cockroach)
+			}
+			if strings.Contains(got, " USING ") {
+				t.Errorf("cockroach statement %q should not cast with USING", got)
+			}
+		})
+	}
+}
+
+func TestPostgresReplicaIdentitySQL(t *testing.T) {
+	tests := []struct {
+		identity string
+		index    string
+		sql      string
+	}{
+		{identity: "DEFAULT"},
+		{identity: "FULL", sql: `ALTER TABLE "accounts" REPLICA IDENTITY FULL;`},
+		{identity: "NOTHING", sql: `ALTER TABLE "accounts" REPLICA IDENTITY NOTHING;`},
+		{identity: "USING INDEX", index: "accounts_email_key", sql: `ALTER TABLE "accounts" REPLICA IDENTITY USING INDEX "accounts_email_key";`},
+		{identity: "USING INDEX"},
+	}
+	for _, tt := range tests {
+		if got := postgresReplicaIdentitySQL("accounts", tt.identity, tt.index); got != tt.sql {
+			t.Errorf("postgresReplicaIdentitySQL(%q, %q) = %q, want %q", tt.identity, tt.index, got, tt.sql)
+		}
+	}
+}
+
+func TestPostgresAlterRenamedColumn(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing []Field
+		fields   []Field
+		sql      string
+	}{
+		{
+			name:     "rename",
+			existing: []Field{{Name: "name", DataType: "varchar", Length: 50, IsNullable: "YES"}},
+			fields:   []Field{{Name: "full_name", OldName: "name", DataType: "varchar", Length: 50, IsNullable: "YES"}},
+			sql:      `ALTER TABLE "users" RENAME COLUMN "name" TO "full_name";`,
+		},
+		{
+			name:     "rerun",
+			existing: []Field{{Name: "full_name", DataType: "varchar", Length: 50, IsNullable: "YES"}},
+			fields:   []Field{{Name: "full_name", OldName: "name", DataType: "varchar", Length: 50, IsNullable: "YES"}},
+		},
+		{
+			name:     "rename and change type",
+			existing: []Field{{Name: "name", DataType: "varchar", Length: 50, IsNullable: "YES"}},
+			fields:   []Field{{Name: "full_name", OldName: "name", DataType: "varchar", Length: 100, IsNullable: "NO"}},
+			sql: `ALTER TABLE "users" RENAME COLUMN "name" TO "full_name";` +
+				`ALTER TABLE "users" ALTER COLUMN "full_name" SET DATA TYPE VARCHAR(100) USING "full_name"::VARCHAR;` +
+				`ALTER TABLE "users" ALTER COLUMN "full_name" SET NOT NULL;`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql, err := cachedPostgres("users", tt.existing).alterSQL("users", tt.fields)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sql != tt.sql {
+				t.Fatalf("alterSQL() = %q, want %q", sql, tt.sql)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package metadata
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFieldsFromSchemaRoundTrip(t *testing.T) {
+	schema := &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"id":         {Type: "integer"},
+			"email":      {Type: "string", MaxLength: 255, Format: "email"},
+			"bio":        {Type: "string", Description: "about the user"},
+			"balance":    {Type: "number", Default: "0"},
+			"active":     {Type: "boolean"},
+			"born":       {Type: "string", Format: "date"},
+			"updated_at": {Type: "string", Format: "date-time"},
+		},
+		Required:    []string{"active", "email"},
+		PrimaryKeys: []string{"id"},
+	}
+	fields := FieldsFromSchema(schema)
+	want := []Field{
+		{Name: "id", DataType: "int", Key: "PRI", IsNullable: "NO"},
+		{Name: "active", DataType: "boolean", IsNullable: "NO"},
+		{Name: "balance", DataType: "numeric", IsNullable: "YES", Default: "0"},
+		{Name: "bio", DataType: "text", IsNullable: "YES", Comment: "about the user"},
+		{Name: "born", DataType: "date", IsNullable: "YES"},
+		{Name: "email", DataType: "varchar", Length: 255, IsNullable: "NO", Format: "email"},
+		{Name: "updated_at", DataType: "timestamp", IsNullable: "YES"},
+	}
+	if !reflect.DeepEqual(fields, want) {
+		t.Fatalf("FieldsFromSchema() = %+v, want %+v", fields, want)
+	}
+	got := AsJsonSchema(fields, false)
+	for name, prop := range schema.Properties {
+		if got.Properties[name].Type != prop.Type || got.Properties[name].Format != prop.Format || got.Properties[name].MaxLength != prop.MaxLength || got.Properties[name].Default != prop.Default {
+			t.Fatalf("property %s = %+v, want %+v", name, got.Properties[name], prop)
+		}
+	}
+	if !reflect.DeepEqual(got.Required, schema.Required) || !reflect.DeepEqual(got.PrimaryKeys, schema.PrimaryKeys) {
+		t.Fatalf("required %v and keys %v, want %v and %v", got.Required, got.PrimaryKeys, schema.Required, schema.PrimaryKeys)
+	}
+}
+
+func TestApplyChecks(t *testing.T) {
+	float := func(f float64) *float64 { return &f }
+	fields := []Field{
+		{Name: "price", DataType: "numeric"},
+		{Name: "qty", DataType: "int"},
+		{Name: "discount", DataType: "int"},
+		{Name: "name", DataType: "varchar"},
+	}
+	tests := []struct {
+		name   string
+		check  string
+		column string
+		want   Schema
+	}{
+		{name: "greater than", check: "price > 0", column: "price", want: Schema{ExclusiveMinimum: float(0)}},
+		{name: "postgres cast", check: "(price > (0)::numeric)", column: "price", want: Schema{ExclusiveMinimum: float(0)}},
+		{name: "between", check: "qty BETWEEN 1 AND 10", column: "qty", want: Schema{Minimum: float(1), Maximum: float(10)}},
+		{name: "and", check: "`qty` >= 1 and `qty` < 100", column: "qty", want: Schema{Minimum: float(1), ExclusiveMaximum: float(100)}},
+		{name: "reversed", check: "0 <= discount", column: "discount", want: Schema{Minimum: float(0)}},
+		{name: "or ignored", check: "qty > 0 OR qty IS NULL", column: "qty"},
+		{name: "string column ignored", check: "name > 5", column: "name"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prop := AsJsonSchema(fields, false).ApplyChecks([]Check{{Expression: tt.check}}).Properties[tt.column]
+			got := Schema{Minimum: prop.Minimum, Maximum: prop.Maximum, ExclusiveMinimum: prop.ExclusiveMinimum, ExclusiveMaximum: prop.ExclusiveMaximum}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("bounds of %s = %+v, want %+v", tt.column, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAsJsonSchemaEnumAndFormat(t *testing.T) {
+	fields := []Field{
+		{Name: "status", DataType: "enum", EnumValues: []string{"active", "inactive"}},
+		{Name: "id", DataType: "uuid"},
+		{Name: "created_at", DataType: "datetime"},
+		{Name: "site", DataType: "varchar", Format: "uri"},
+		{Name: "qty", DataType: "int"},
+	}
+	schema := AsJsonSchema(fields, false)
+	tests := []struct {
+		column string
+		typ    string
+		format string
+		enum   []string
+	}{
+		{column: "status", typ: "string", enum: []string{"active", "inactive"}},
+		{column: "id", typ: "string", format: "uuid"},
+		{column: "created_at", typ: "string", format: "date-time"},
+		{column: "site", typ: "string", format: "uri"},
+		{column: "qty", typ: "integer"},
+	}
+	for _, tt := range tests {
+		prop := schema.Properties[tt.column]
+		if prop.Type != tt.typ || prop.Format != tt.format || !reflect.DeepEqual(prop.Enum, tt.enum) {
+			t.Fatalf("property %s = %+v, want type %s, format %q, enum %v", tt.column, prop, tt.typ, tt.format, tt.enum)
+		}
+	}
+}
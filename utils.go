@@ -1,6 +1,10 @@
 package metadata
 
 import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
 	"unsafe"
 )
 
@@ -12,3 +16,107 @@ func FromByte(b []byte) string {
 	p := unsafe.SliceData(b)
 	return unsafe.String(p, len(b))
 }
+
+// splitStatements splits sql into individual statements on semicolons. Semicolons
+// inside string literals, quoted identifiers, comments and Postgres dollar-quoted
+// bodies don't end a statement. Empty statements are skipped.
+func splitStatements(sql string) []string {
+	var statements []string
+	start := 0
+	for i := 0; i < len(sql); i++ {
+		switch c := sql[i]; {
+		case c == '\'' || c == '"' || c == '`':
+			i = skipQuoted(sql, i, c)
+		case c == '-' && strings.HasPrefix(sql[i:], "--"):
+			if end := strings.IndexByte(sql[i:], '\n'); end >= 0 {
+				i += end
+			} else {
+				i = len(sql)
+			}
+		case c == '/' && strings.HasPrefix(sql[i:], "/*"):
+			if end := strings.Index(sql[i+2:], "*/"); end >= 0 {
+				i += end + 3
+			} else {
+				i = len(sql)
+			}
+		case c == '$':
+			if tag := dollarQuoteTag(sql[i:]); tag != "" {
+				if end := strings.Index(sql[i+len(tag):], tag); end >= 0 {
+					i += len(tag) + end + len(tag) - 1
+				} else {
+					i = len(sql)
+				}
+			}
+		case c == ';':
+			if s := strings.TrimSpace(sql[start:i]); s != "" {
+				statements = append(statements, s)
+			}
+			start = i + 1
+		}
+	}
+	if start < len(sql) {
+		if s := strings.TrimSpace(sql[start:]); s != "" {
+			statements = append(statements, s)
+		}
+	}
+	return statements
+}
+
+// skipQuoted returns the index of the quote closing the quoted text starting at
+// i. Doubled quotes are escapes and don't close it.
+func skipQuoted(sql string, i int, quote byte) int {
+	for j := i + 1; j < len(sql); j++ {
+		if sql[j] != quote {
+			continue
+		}
+		if j+1 < len(sql) && sql[j+1] == quote {
+			j++
+			continue
+		}
+		return j
+	}
+	return len(sql)
+}
+
+// dollarQuoteTag returns the opening tag, such as $$ or $body$, when s starts
+// with a Postgres dollar quote.
+func dollarQuoteTag(s string) string {
+	for j := 1; j < len(s); j++ {
+		c := s[j]
+		switch {
+		case c == '$':
+			return s[:j+1]
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || j > 1 && c >= '0' && c <= '9':
+		default:
+			return ""
+		}
+	}
+	return ""
+}
+
+// expandSliceParams expands every slice param bound in query, such as ids in
+// "id IN (:ids)", into one param per element: "id IN (:ids_0, :ids_1)". An
+// empty slice expands to NULL, so the IN clause matches nothing.
+func expandSliceParams(query string, params map[string]any) (string, map[string]any) {
+	expanded := make(map[string]any, len(params))
+	for key, val := range params {
+		v := reflect.ValueOf(val)
+		if val == nil || v.Kind() != reflect.Slice || v.Type().Elem().Kind() == reflect.Uint8 {
+			expanded[key] = val
+			continue
+		}
+		names := make([]string, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			name := fmt.Sprintf("%s_%d", key, i)
+			names[i] = ":" + name
+			expanded[name] = v.Index(i).Interface()
+		}
+		list := strings.Join(names, ", ")
+		if list == "" {
+			list = "NULL"
+		}
+		placeholder := regexp.MustCompile(`(^|[^:]):` + regexp.QuoteMeta(key) + `\b`)
+		query = placeholder.ReplaceAllString(query, "${1}"+list)
+	}
+	return query, expanded
+}
@@ -0,0 +1,155 @@
+package metadata
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitStatementsStringLiterals(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		want []string
+	}{
+		{name: "plain", sql: "CREATE TABLE a (id int);CREATE TABLE b (id int);", want: []string{"CREATE TABLE a (id int)", "CREATE TABLE b (id int)"}},
+		{name: "empty statements", sql: ";; CREATE TABLE a (id int);  ;\n", want: []string{"CREATE TABLE a (id int)"}},
+		{name: "default with semicolon", sql: "CREATE TABLE a (sep varchar(5) DEFAULT ';');SELECT 1", want: []string{"CREATE TABLE a (sep varchar(5) DEFAULT ';')", "SELECT 1"}},
+		{name: "escaped quote", sql: "INSERT INTO a VALUES ('it''s; fine');SELECT 1;", want: []string{"INSERT INTO a VALUES ('it''s; fine')", "SELECT 1"}},
+		{name: "quoted identifiers", sql: "SELECT \"a;b\", `c;d` FROM t;SELECT 2", want: []string{"SELECT \"a;b\", `c;d` FROM t", "SELECT 2"}},
+		{name: "no trailing semicolon", sql: "SELECT 1", want: []string{"SELECT 1"}},
+		{name: "empty", sql: "  "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := splitStatements(tt.sql); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("splitStatements(%q) = %q, want %q", tt.sql, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCloneTableKeepsSemicolonsInLiterals(t *testing.T) {
+	src := &fakeSource{
+		dialect: "postgres",
+		fields: map[string][]Field{
+			"notes": {{Name: "id", DataType: "int", Key: "PRI", IsNullable: "NO"}, {Name: "sep", DataType: "varchar"}},
+		},
+		checks: map[string][]Check{
+			"notes": {{Name: "notes_sep_check", Expression: "sep <> ';'"}},
+		},
+	}
+	dest := &fakeSource{dialect: "postgres", transactions: true}
+	if err := CloneTable(src, dest, "notes", "notes_copy"); err != nil {
+		t.Fatal(err)
+	}
+	if len(dest.executed) != 2 {
+		t.Fatalf("expected the create and the check, got %q", dest.executed)
+	}
+	if !strings.HasSuffix(dest.executed[1], "CHECK (sep <> ';')") {
+		t.Fatalf("check statement split apart: %q", dest.executed[1])
+	}
+}
+
+func TestSplitStatementsDollarQuotesAndComments(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		want []string
+	}{
+		{
+			name: "dollar quoted body",
+			sql:  "CREATE FUNCTION f() RETURNS int AS $$ BEGIN RETURN 1; END $$ LANGUAGE plpgsql;SELECT f();",
+			want: []string{"CREATE FUNCTION f() RETURNS int AS $$ BEGIN RETURN 1; END $$ LANGUAGE plpgsql", "SELECT f()"},
+		},
+		{
+			name: "tagged dollar quote",
+			sql:  "DO $body$ BEGIN PERFORM 1; PERFORM '$$'; END $body$;SELECT 1",
+			want: []string{"DO $body$ BEGIN PERFORM 1; PERFORM '$$'; END $body$", "SELECT 1"},
+		},
+		{
+			name: "positional parameter",
+			sql:  "SELECT $1;SELECT $2",
+			want: []string{"SELECT $1", "SELECT $2"},
+		},
+		{
+			name: "line comment",
+			sql:  "SELECT 1; -- done; really\nSELECT 2",
+			want: []string{"SELECT 1", "-- done; really\nSELECT 2"},
+		},
+		{
+			name: "block comment",
+			sql:  "SELECT /* a; b */ 1;SELECT 2",
+			want: []string{"SELECT /* a; b */ 1", "SELECT 2"},
+		},
+		{
+			name: "unterminated dollar quote",
+			sql:  "DO $$ BEGIN; END",
+			want: []string{"DO $$ BEGIN; END"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := splitStatements(tt.sql); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("splitStatements(%q) = %q, want %q", tt.sql, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandSliceParams(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		params     map[string]any
+		wantQuery  string
+		wantParams map[string]any
+	}{
+		{
+			name:       "ints",
+			query:      "SELECT * FROM t WHERE id IN (:ids)",
+			params:     map[string]any{"ids": []int{1, 2, 3}},
+			wantQuery:  "SELECT * FROM t WHERE id IN (:ids_0, :ids_1, :ids_2)",
+			wantParams: map[string]any{"ids_0": 1, "ids_1": 2, "ids_2": 3},
+		},
+		{
+			name:       "scalars untouched",
+			query:      "SELECT * FROM t WHERE name = :name AND id IN (:ids)",
+			params:     map[string]any{"name": "ada", "ids": []string{"a"}},
+			wantQuery:  "SELECT * FROM t WHERE name = :name AND id IN (:ids_0)",
+			wantParams: map[string]any{"name": "ada", "ids_0": "a"},
+		},
+		{
+			name:       "empty slice",
+			query:      "SELECT * FROM t WHERE id IN (:ids)",
+			params:     map[string]any{"ids": []int{}},
+			wantQuery:  "SELECT * FROM t WHERE id IN (NULL)",
+			wantParams: map[string]any{},
+		},
+		{
+			name:       "bytes are a value",
+			query:      "SELECT * FROM t WHERE hash = :hash",
+			params:     map[string]any{"hash": []byte("ab")},
+			wantQuery:  "SELECT * FROM t WHERE hash = :hash",
+			wantParams: map[string]any{"hash": []byte("ab")},
+		},
+		{
+			name:       "prefix of another param and casts",
+			query:      "SELECT * FROM t WHERE id IN (:id) AND ids_total = :ids_total AND x = y::ids",
+			params:     map[string]any{"id": []int{7}, "ids_total": 2},
+			wantQuery:  "SELECT * FROM t WHERE id IN (:id_0) AND ids_total = :ids_total AND x = y::ids",
+			wantParams: map[string]any{"id_0": 7, "ids_total": 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, params := expandSliceParams(tt.query, tt.params)
+			if query != tt.wantQuery {
+				t.Fatalf("query = %q, want %q", query, tt.wantQuery)
+			}
+			if !reflect.DeepEqual(params, tt.wantParams) {
+				t.Fatalf("params = %v, want %v", params, tt.wantParams)
+			}
+		})
+	}
+}